@@ -1,31 +1,45 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"pow/pkg/editor"
+	"strings"
 )
 
+// version is set when building a release, with
+// -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
 func main() {
-	var filename string
-	var err error
-	var app *editor.Editor
-
-	// Check if a filename was provided as an argument
-	if len(os.Args) > 1 {
-		filename = os.Args[1]
-		app, err = editor.NewEditor(filename)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error initializing editor: %v\n", err)
-			os.Exit(1)
-		}
-	} else {
-		// No filename provided, initialize with empty file
-		app, err = editor.NewEditor("")
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error initializing editor: %v\n", err)
-			os.Exit(1)
-		}
+	options, args, showVersion, err := parseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		usage(os.Stdout)
+		return
+	}
+	if showVersion {
+		fmt.Println("pow", version)
+		return
+	}
+
+	// Report bad flags and arguments before the screen is taken over
+	if err == nil {
+		err = options.Validate(args...)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pow: %v\n", err)
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	// Open each file given as an argument, or an empty file if there are none
+	app, err := editor.NewEditor(options, args...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing editor: %v\n", err)
+		os.Exit(1)
 	}
 
 	if err := app.Run(); err != nil {
@@ -33,3 +47,69 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newFlagSet returns the command line flags, setting options as they are
+// parsed
+func newFlagSet(options *editor.Options, showVersion *bool) *flag.FlagSet {
+	flags := flag.NewFlagSet("pow", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&options.ConfigPath, "config", "", "read the `file` instead of config/config.conf")
+	flags.StringVar(&options.Theme, "theme", "", "use the theme `name`d in config/themes, or the theme file at a path")
+	flags.BoolVar(&options.ReadOnly, "readonly", false, "open the files without allowing changes")
+	flags.StringVar(&options.Line, "line", "", "open the first file at `line`, or line:column")
+	flags.StringVar(&options.Syntax, "syntax", "", "highlight every file as `language`, such as go or python")
+	flags.BoolVar(showVersion, "version", false, "print the version and exit")
+	return flags
+}
+
+// parseFlags reads the options from the command line and returns them with
+// the file arguments among them, and whether the version was asked for.
+// Flags may come before or between the files; "--" ends them, for file
+// names starting with '-' or '+'.
+func parseFlags(arguments []string) (editor.Options, []string, bool, error) {
+	var options editor.Options
+	showVersion := false
+	flags := newFlagSet(&options, &showVersion)
+
+	var args []string
+	for {
+		if err := flags.Parse(arguments); err != nil {
+			return options, nil, false, err
+		}
+		rest := flags.Args()
+
+		// Pass "--" on, so a file named "+1" after it isn't taken for a line
+		if consumed := len(arguments) - len(rest); consumed > 0 && arguments[consumed-1] == "--" {
+			args = append(append(args, "--"), rest...)
+			break
+		}
+
+		// Take the files up to the next flag, then read on from it
+		i := 0
+		for i < len(rest) && (!strings.HasPrefix(rest[i], "-") || rest[i] == "-") {
+			i++
+		}
+		args = append(args, rest[:i]...)
+		if i == len(rest) {
+			break
+		}
+		arguments = rest[i:]
+	}
+	return options, args, showVersion, nil
+}
+
+// usage writes how to run pow and the flags it takes
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: pow [flags] [+line[:column]] [file[:line[:column]]]...")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags:")
+	newFlagSet(&editor.Options{}, new(bool)).VisitAll(func(f *flag.Flag) {
+		name, text := flag.UnquoteUsage(f)
+		spec := "--" + f.Name
+		if name != "" {
+			spec += " " + name
+		}
+		fmt.Fprintf(w, "  %-20s %s\n", spec, text)
+	})
+	fmt.Fprintf(w, "  %-20s %s\n", "--help", "print this help and exit")
+}
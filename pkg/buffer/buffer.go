@@ -0,0 +1,380 @@
+package buffer
+
+import (
+	"io"
+	"math/rand/v2"
+	"strings"
+)
+
+// maxChunk is the largest piece of text stored in a single node. Small
+// chunks keep edits cheap, large ones keep the tree shallow.
+const maxChunk = 1024
+
+// node is an element of the rope. The rope is a treap: nodes are ordered by
+// text position and heap ordered by a random priority, which keeps the tree
+// balanced in expectation so every operation takes O(log n).
+type node struct {
+	text     string
+	newlines int // newlines in text
+	priority uint32
+	left     *node
+	right    *node
+
+	// Totals for the subtree rooted at this node
+	size  int
+	lines int
+}
+
+// newNode creates a detached node holding text
+func newNode(text string) *node {
+	n := &node{
+		text:     text,
+		newlines: strings.Count(text, "\n"),
+		priority: rand.Uint32(),
+	}
+	n.update()
+	return n
+}
+
+// update recomputes the subtree totals from the children
+func (n *node) update() {
+	n.size = len(n.text) + sizeOf(n.left) + sizeOf(n.right)
+	n.lines = n.newlines + linesOf(n.left) + linesOf(n.right)
+}
+
+// sizeOf returns the number of bytes under n
+func sizeOf(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+// linesOf returns the number of newlines under n
+func linesOf(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return n.lines
+}
+
+// merge joins two trees where every byte of a comes before every byte of b
+func merge(a, b *node) *node {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.priority > b.priority {
+		a.right = merge(a.right, b)
+		a.update()
+		return a
+	}
+	b.left = merge(a, b.left)
+	b.update()
+	return b
+}
+
+// split divides n into the bytes before offset and the bytes from offset on
+func split(n *node, offset int) (*node, *node) {
+	if n == nil {
+		return nil, nil
+	}
+
+	leftSize := sizeOf(n.left)
+	switch {
+	case offset <= leftSize:
+		a, b := split(n.left, offset)
+		n.left = b
+		n.update()
+		return a, n
+
+	case offset >= leftSize+len(n.text):
+		a, b := split(n.right, offset-leftSize-len(n.text))
+		n.right = a
+		n.update()
+		return n, b
+	}
+
+	// The offset falls inside this node's text, so cut the text in two
+	cut := offset - leftSize
+	right := merge(newNode(n.text[cut:]), n.right)
+	n.text = n.text[:cut]
+	n.newlines = strings.Count(n.text, "\n")
+	n.right = nil
+	n.update()
+	return n, right
+}
+
+// join merges two trees like merge, combining the chunks on either side of
+// the seam where they fit in one. An edit splits the rope at the seam, and
+// may have cut down the chunks next to it, so each of those is first
+// combined with its other neighbour. This keeps any two neighbouring chunks
+// too large to fit in one, so the rope doesn't break up into ever smaller
+// chunks as it is edited.
+func join(a, b *node) *node {
+	var last, first string
+	if a != nil {
+		a, last = takeLast(a)
+	}
+	if b != nil {
+		first, b = takeFirst(b)
+	}
+
+	if a != nil {
+		rest, before := takeLast(a)
+		if len(before)+len(last) <= maxChunk {
+			a, last = rest, before+last
+		} else {
+			a = merge(rest, newNode(before))
+		}
+	}
+	if b != nil {
+		after, rest := takeFirst(b)
+		if len(first)+len(after) <= maxChunk {
+			first, b = first+after, rest
+		} else {
+			b = merge(newNode(after), rest)
+		}
+	}
+
+	if len(last)+len(first) <= maxChunk {
+		return merge(merge(a, build(last+first)), b)
+	}
+	return merge(merge(a, newNode(last)), merge(newNode(first), b))
+}
+
+// takeLast removes the last chunk from n, returning the rest and its text
+func takeLast(n *node) (*node, string) {
+	last := n
+	for last.right != nil {
+		last = last.right
+	}
+	rest, _ := split(n, sizeOf(n)-len(last.text))
+	return rest, last.text
+}
+
+// takeFirst removes the first chunk from n, returning its text and the rest
+func takeFirst(n *node) (string, *node) {
+	first := n
+	for first.left != nil {
+		first = first.left
+	}
+	_, rest := split(n, len(first.text))
+	return first.text, rest
+}
+
+// build creates a tree from text, cut into chunks of at most maxChunk bytes
+func build(text string) *node {
+	var root *node
+	for len(text) > 0 {
+		size := min(len(text), maxChunk)
+		root = merge(root, newNode(text[:size]))
+		text = text[size:]
+	}
+	return root
+}
+
+// Buffer is a text buffer backed by a rope. Offsets are byte offsets into
+// the text; lines are separated by '\n' and numbered from 0.
+type Buffer struct {
+	root *node
+}
+
+// New creates a buffer holding text
+func New(text string) *Buffer {
+	return &Buffer{root: build(text)}
+}
+
+// Len returns the length of the text in bytes
+func (b *Buffer) Len() int {
+	return sizeOf(b.root)
+}
+
+// LineCount returns the number of lines, which is one more than the number
+// of newlines
+func (b *Buffer) LineCount() int {
+	return linesOf(b.root) + 1
+}
+
+// Insert adds text at offset
+func (b *Buffer) Insert(offset int, text string) {
+	if text == "" {
+		return
+	}
+	offset = b.clamp(offset)
+
+	left, right := split(b.root, offset)
+	b.root = join(join(left, build(text)), right)
+}
+
+// Delete removes the text between start and end
+func (b *Buffer) Delete(start, end int) {
+	start, end = b.clamp(start), b.clamp(end)
+	if start >= end {
+		return
+	}
+
+	left, rest := split(b.root, start)
+	_, right := split(rest, end-start)
+	b.root = join(left, right)
+}
+
+// Slice returns the text between start and end
+func (b *Buffer) Slice(start, end int) string {
+	start, end = b.clamp(start), b.clamp(end)
+	if start >= end {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.Grow(end - start)
+	collect(b.root, start, end, &sb)
+	return sb.String()
+}
+
+// collect writes the bytes of n between start and end to sb
+func collect(n *node, start, end int, sb *strings.Builder) {
+	if n == nil || start >= end {
+		return
+	}
+
+	leftSize := sizeOf(n.left)
+	if start < leftSize {
+		collect(n.left, start, min(end, leftSize), sb)
+	}
+
+	textStart := max(start-leftSize, 0)
+	textEnd := min(end-leftSize, len(n.text))
+	if textStart < textEnd {
+		sb.WriteString(n.text[textStart:textEnd])
+	}
+
+	rightStart := leftSize + len(n.text)
+	if end > rightStart {
+		collect(n.right, max(start-rightStart, 0), end-rightStart, sb)
+	}
+}
+
+// String returns the whole text
+func (b *Buffer) String() string {
+	return b.Slice(0, b.Len())
+}
+
+// WriteTo writes the whole text to w without building it in memory first
+func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	var walk func(n *node) error
+	walk = func(n *node) error {
+		if n == nil {
+			return nil
+		}
+		if err := walk(n.left); err != nil {
+			return err
+		}
+		count, err := io.WriteString(w, n.text)
+		written += int64(count)
+		if err != nil {
+			return err
+		}
+		return walk(n.right)
+	}
+	err := walk(b.root)
+	return written, err
+}
+
+// LineStart returns the offset of the first byte of line
+func (b *Buffer) LineStart(line int) int {
+	if line <= 0 {
+		return 0
+	}
+	if line > linesOf(b.root) {
+		return b.Len()
+	}
+
+	// Find the offset just past the line-th newline
+	offset := 0
+	n := b.root
+	for n != nil {
+		if line <= linesOf(n.left) {
+			n = n.left
+			continue
+		}
+		line -= linesOf(n.left)
+		offset += sizeOf(n.left)
+		if line <= n.newlines {
+			return offset + nthNewline(n.text, line) + 1
+		}
+		line -= n.newlines
+		offset += len(n.text)
+		n = n.right
+	}
+	return offset
+}
+
+// nthNewline returns the index of the nth newline in text, counting from 1
+func nthNewline(text string, nth int) int {
+	index := -1
+	for ; nth > 0; nth-- {
+		index += strings.IndexByte(text[index+1:], '\n') + 1
+	}
+	return index
+}
+
+// LineEnd returns the offset just past the last character of line,
+// excluding its newline
+func (b *Buffer) LineEnd(line int) int {
+	if line >= linesOf(b.root) {
+		return b.Len()
+	}
+	return b.LineStart(line+1) - 1
+}
+
+// LineLen returns the length of line in bytes, excluding its newline
+func (b *Buffer) LineLen(line int) int {
+	return b.LineEnd(line) - b.LineStart(line)
+}
+
+// Line returns the text of line without its newline
+func (b *Buffer) Line(line int) string {
+	return b.Slice(b.LineStart(line), b.LineEnd(line))
+}
+
+// Offset converts a line and column to a byte offset
+func (b *Buffer) Offset(line, col int) int {
+	return b.clamp(b.LineStart(line) + col)
+}
+
+// Position converts a byte offset to a line and column
+func (b *Buffer) Position(offset int) (int, int) {
+	offset = b.clamp(offset)
+
+	// Count the newlines before offset
+	line := 0
+	remaining := offset
+	n := b.root
+	for n != nil {
+		leftSize := sizeOf(n.left)
+		if remaining < leftSize {
+			n = n.left
+			continue
+		}
+		line += linesOf(n.left)
+		remaining -= leftSize
+		if remaining <= len(n.text) {
+			line += strings.Count(n.text[:remaining], "\n")
+			break
+		}
+		line += n.newlines
+		remaining -= len(n.text)
+		n = n.right
+	}
+
+	return line, offset - b.LineStart(line)
+}
+
+// clamp limits offset to the bounds of the text
+func (b *Buffer) clamp(offset int) int {
+	return max(0, min(offset, b.Len()))
+}
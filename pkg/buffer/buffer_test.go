@@ -0,0 +1,120 @@
+package buffer
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+// randomText returns up to n bytes of text made of a few letters and
+// newlines, so that edits often land on line boundaries
+func randomText(r *rand.Rand, n int) string {
+	const alphabet = "ab\n\nxyz\n"
+	var sb strings.Builder
+	for i := r.IntN(n + 1); i > 0; i-- {
+		sb.WriteByte(alphabet[r.IntN(len(alphabet))])
+	}
+	return sb.String()
+}
+
+// chunks returns the text of every node in order
+func chunks(n *node) []string {
+	if n == nil {
+		return nil
+	}
+	return append(append(chunks(n.left), n.text), chunks(n.right)...)
+}
+
+// checkBuffer compares the queries on b with the same queries answered from
+// want, a plain string holding the expected text. Lines are looked up at a
+// sample of positions picked with r.
+func checkBuffer(t *testing.T, r *rand.Rand, b *Buffer, want string) {
+	t.Helper()
+
+	if got := b.String(); got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+	if b.Len() != len(want) {
+		t.Fatalf("Len() = %d, want %d", b.Len(), len(want))
+	}
+
+	lines := strings.Split(want, "\n")
+	if b.LineCount() != len(lines) {
+		t.Fatalf("LineCount() = %d, want %d", b.LineCount(), len(lines))
+	}
+
+	starts := make([]int, len(lines))
+	for i := 1; i < len(lines); i++ {
+		starts[i] = starts[i-1] + len(lines[i-1]) + 1
+	}
+	for range 20 {
+		i := r.IntN(len(lines))
+		line, start := lines[i], starts[i]
+		if got := b.LineStart(i); got != start {
+			t.Fatalf("LineStart(%d) = %d, want %d", i, got, start)
+		}
+		if got := b.Line(i); got != line {
+			t.Fatalf("Line(%d) = %q, want %q", i, got, line)
+		}
+		for _, col := range []int{0, len(line) / 2, len(line)} {
+			gotLine, gotCol := b.Position(start + col)
+			if gotLine != i || gotCol != col {
+				t.Fatalf("Position(%d) = %d, %d, want %d, %d", start+col, gotLine, gotCol, i, col)
+			}
+		}
+	}
+
+	// Neighbouring chunks that would fit in one should have been joined
+	pieces := chunks(b.root)
+	for i := 1; i < len(pieces); i++ {
+		if len(pieces[i-1])+len(pieces[i]) <= maxChunk {
+			t.Fatalf("chunks %d and %d hold %d and %d bytes, which fit in one",
+				i-1, i, len(pieces[i-1]), len(pieces[i]))
+		}
+	}
+}
+
+func TestBufferMatchesString(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	for round := 0; round < 20; round++ {
+		want := randomText(r, 3*maxChunk)
+		b := New(want)
+		checkBuffer(t, r, b, want)
+
+		for step := 0; step < 200; step++ {
+			offset := r.IntN(len(want) + 1)
+			if r.IntN(2) == 0 {
+				text := randomText(r, 2*maxChunk)
+				if r.IntN(4) != 0 {
+					text = randomText(r, 8)
+				}
+				b.Insert(offset, text)
+				want = want[:offset] + text + want[offset:]
+			} else {
+				end := min(offset+r.IntN(maxChunk), len(want))
+				b.Delete(offset, end)
+				want = want[:offset] + want[end:]
+			}
+			checkBuffer(t, r, b, want)
+		}
+	}
+}
+
+func TestBufferClampsOffsets(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	b := New("one\ntwo")
+
+	b.Insert(100, "!")
+	b.Insert(-5, "<")
+	b.Delete(-10, 1)
+	b.Delete(5, 100)
+	checkBuffer(t, r, b, "one\nt")
+
+	if got := b.Slice(-1, 100); got != "one\nt" {
+		t.Fatalf("Slice(-1, 100) = %q", got)
+	}
+	if line, col := b.Position(100); line != 1 || col != 1 {
+		t.Fatalf("Position(100) = %d, %d, want 1, 1", line, col)
+	}
+}
@@ -0,0 +1,147 @@
+package clipboard
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Backend moves text to and from a system clipboard
+type Backend interface {
+	// Name returns a short description of the backend
+	Name() string
+	// Copy places text on the clipboard
+	Copy(text string) error
+	// Paste returns the text currently on the clipboard
+	Paste() (string, error)
+}
+
+// Clipboard copies and pastes through the best available backend. Every copy
+// is also kept in an internal register, which paste falls back to when no
+// system clipboard can be reached.
+type Clipboard struct {
+	backend  Backend
+	osc52    func([]byte)
+	register string
+}
+
+// New creates a clipboard using the first backend found on this system.
+// osc52 is used to send copies to the terminal when no backend is found,
+// which also reaches the local clipboard over SSH; it may be nil.
+func New(osc52 func([]byte)) *Clipboard {
+	return &Clipboard{
+		backend: detectBackend(),
+		osc52:   osc52,
+	}
+}
+
+// Name returns a description of where copied text ends up
+func (c *Clipboard) Name() string {
+	if c.backend != nil {
+		return c.backend.Name()
+	}
+	if c.osc52 != nil {
+		return "osc52"
+	}
+	return "internal"
+}
+
+// Copy places text on the clipboard
+func (c *Clipboard) Copy(text string) error {
+	c.register = text
+
+	if c.backend != nil {
+		return c.backend.Copy(text)
+	}
+	if c.osc52 != nil {
+		c.osc52([]byte(text))
+	}
+	return nil
+}
+
+// Paste returns the text on the clipboard, or the internal register if the
+// system clipboard is unavailable
+func (c *Clipboard) Paste() string {
+	if c.backend != nil {
+		if text, err := c.backend.Paste(); err == nil && text != "" {
+			return text
+		}
+	}
+	return c.register
+}
+
+// commandBackend talks to the clipboard through external helper programs
+type commandBackend struct {
+	name     string
+	copyCmd  []string
+	pasteCmd []string
+}
+
+// Name returns the helper program name
+func (b *commandBackend) Name() string {
+	return b.name
+}
+
+// Copy pipes text into the copy helper
+func (b *commandBackend) Copy(text string) error {
+	cmd := exec.Command(b.copyCmd[0], b.copyCmd[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+// Paste reads the output of the paste helper
+func (b *commandBackend) Paste() (string, error) {
+	out, err := exec.Command(b.pasteCmd[0], b.pasteCmd[1:]...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// detectBackend returns the first clipboard helper usable in this session,
+// or nil if there is none
+func detectBackend() Backend {
+	candidates := []*commandBackend{}
+
+	switch {
+	case runtime.GOOS == "darwin":
+		candidates = append(candidates, &commandBackend{
+			name:     "pbcopy",
+			copyCmd:  []string{"pbcopy"},
+			pasteCmd: []string{"pbpaste"},
+		})
+
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		candidates = append(candidates, &commandBackend{
+			name:     "wl-copy",
+			copyCmd:  []string{"wl-copy"},
+			pasteCmd: []string{"wl-paste", "--no-newline"},
+		})
+		fallthrough
+
+	case os.Getenv("DISPLAY") != "":
+		candidates = append(candidates,
+			&commandBackend{
+				name:     "xclip",
+				copyCmd:  []string{"xclip", "-selection", "clipboard", "-in"},
+				pasteCmd: []string{"xclip", "-selection", "clipboard", "-out"},
+			},
+			&commandBackend{
+				name:     "xsel",
+				copyCmd:  []string{"xsel", "--clipboard", "--input"},
+				pasteCmd: []string{"xsel", "--clipboard", "--output"},
+			})
+	}
+
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate.copyCmd[0]); err != nil {
+			continue
+		}
+		if _, err := exec.LookPath(candidate.pasteCmd[0]); err != nil {
+			continue
+		}
+		return candidate
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Settings holds the editor options read from the main config file
+type Settings struct {
+	// Number of columns between tab stops
+	TabWidth int
+	// Insert spaces instead of a tab character when Tab is pressed
+	ExpandTabs bool
+	// Line number gutter mode, one of the LineNumbers constants
+	LineNumbers string
+	// Wrap long lines onto several screen rows instead of scrolling sideways
+	SoftWrap bool
+	// Show two rows of shortcuts below the status line
+	ShortcutBar bool
+	// Check open files for changes made by other programs
+	WatchFiles bool
+	// Keep a copy of modified buffers in swap files for crash recovery
+	SwapFiles bool
+	// Copy the previous version of a file aside when saving, one of the
+	// Backup constants
+	Backup string
+	// Directory backups are kept in, or empty to keep them next to the file
+	BackupDir string
+	// Number of numbered backups kept for each file
+	BackupCount int
+	// Keys bound to commands, in the order they appear in the file
+	Bindings []KeyBinding
+}
+
+// KeyBinding binds a key, such as "ctrl+s", to a named command. Keys and
+// command names are checked by the editor, which knows the commands.
+type KeyBinding struct {
+	Key     string
+	Command string
+	Line    int
+}
+
+// Backup modes
+const (
+	BackupOff      = "off"
+	BackupSimple   = "simple"
+	BackupNumbered = "numbered"
+)
+
+// Line number gutter modes
+const (
+	LineNumbersOff      = "off"
+	LineNumbersAbsolute = "absolute"
+	LineNumbersRelative = "relative"
+)
+
+// DefaultSettings returns the settings used when the config file does not
+// override them
+func DefaultSettings() *Settings {
+	return &Settings{
+		TabWidth:    4,
+		ExpandTabs:  true,
+		LineNumbers: LineNumbersOff,
+		WatchFiles:  true,
+		SwapFiles:   true,
+		Backup:      BackupOff,
+		BackupCount: 5,
+	}
+}
+
+// LoadSettings reads editor options from the main config file. Invalid
+// lines are skipped and returned as problems, to be shown once the editor
+// is up; a missing file yields the defaults.
+func LoadSettings(configPath string) (*Settings, []string, error) {
+	settings := DefaultSettings()
+	var problems []string
+
+	file, err := os.Open(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil, nil
+		}
+		return settings, nil, fmt.Errorf("failed to open config file '%s': %w", configPath, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	section := ""
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip comments and empty lines
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// A [section] header applies to the lines below it
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section != "keys" {
+				problems = append(problems, fmt.Sprintf("Unknown section in '%s' line %d: %s", configPath, lineNum, section))
+			}
+			continue
+		}
+
+		// Parse settings (key = value)
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			problems = append(problems, fmt.Sprintf("Invalid syntax in '%s' line %d, expected 'key = value'", configPath, lineNum))
+			continue
+		}
+
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		// Key bindings, either in the [keys] section or as "bind key = command"
+		if binding, found := strings.CutPrefix(key, "bind "); found || section == "keys" {
+			if found {
+				key = strings.TrimSpace(binding)
+			}
+			settings.Bindings = append(settings.Bindings, KeyBinding{Key: key, Command: value, Line: lineNum})
+			continue
+		}
+		if section != "" {
+			continue
+		}
+
+		var parseErr error
+		switch key {
+		case "theme":
+			// Handled by the theme loader
+		case "tab_width":
+			var width int
+			if width, parseErr = parsePositiveInt(value); parseErr == nil {
+				settings.TabWidth = width
+			}
+		case "expand_tabs":
+			var expand bool
+			if expand, parseErr = strconv.ParseBool(value); parseErr == nil {
+				settings.ExpandTabs = expand
+			}
+		case "soft_wrap":
+			var wrap bool
+			if wrap, parseErr = strconv.ParseBool(value); parseErr == nil {
+				settings.SoftWrap = wrap
+			}
+		case "shortcut_bar":
+			var show bool
+			if show, parseErr = strconv.ParseBool(value); parseErr == nil {
+				settings.ShortcutBar = show
+			}
+		case "watch_files":
+			var watch bool
+			if watch, parseErr = strconv.ParseBool(value); parseErr == nil {
+				settings.WatchFiles = watch
+			}
+		case "swap_files":
+			var swap bool
+			if swap, parseErr = strconv.ParseBool(value); parseErr == nil {
+				settings.SwapFiles = swap
+			}
+		case "backup":
+			switch value {
+			case BackupOff, BackupSimple, BackupNumbered:
+				settings.Backup = value
+			default:
+				parseErr = fmt.Errorf("expected off, simple or numbered, got '%s'", value)
+			}
+		case "backup_dir":
+			settings.BackupDir, parseErr = expandHome(value)
+		case "backup_count":
+			var count int
+			if count, parseErr = parsePositiveInt(value); parseErr == nil {
+				settings.BackupCount = count
+			}
+		case "line_numbers":
+			switch value {
+			case LineNumbersOff, LineNumbersAbsolute, LineNumbersRelative:
+				settings.LineNumbers = value
+			default:
+				parseErr = fmt.Errorf("expected off, absolute or relative, got '%s'", value)
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("Unknown setting in '%s' line %d: %s", configPath, lineNum, key))
+		}
+
+		if parseErr != nil {
+			problems = append(problems, fmt.Sprintf("Invalid value for '%s' in '%s' line %d: %v", key, configPath, lineNum, parseErr))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return settings, problems, fmt.Errorf("error reading config file '%s': %w", configPath, err)
+	}
+
+	return settings, problems, nil
+}
+
+// parsePositiveInt parses a whole number greater than zero
+func parsePositiveInt(s string) (int, error) {
+	val, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if val <= 0 {
+		return 0, fmt.Errorf("value must be greater than 0, got %d", val)
+	}
+	return val, nil
+}
+
+// expandHome replaces a leading "~/" in path with the user's home directory
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
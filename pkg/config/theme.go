@@ -35,6 +35,15 @@ type Theme struct {
 	TextColor       tcell.Color
 	CursorColor     tcell.Color
 
+	// Line number gutter and current line colors
+	LineNumberColor        tcell.Color
+	CurrentLineNumberColor tcell.Color
+	CurrentLineBackground  tcell.Color
+
+	// Selection colors
+	SelectionBackground tcell.Color
+	SelectionForeground tcell.Color
+
 	// Status line colors
 	StatusBackground tcell.Color
 	StatusForeground tcell.Color
@@ -62,32 +71,7 @@ type Theme struct {
 // LoadTheme loads color configuration from the specified file
 func LoadTheme(configPath string) (*Theme, error) {
 	// Create default theme first (fallback)
-	theme := &Theme{
-		BackgroundColor:  tcell.NewRGBColor(40, 44, 52),    // Dark background
-		TextColor:        tcell.NewRGBColor(220, 223, 228), // Light text
-		CursorColor:      tcell.NewRGBColor(255, 165, 0),   // Orange cursor
-		StatusBackground: tcell.NewRGBColor(45, 50, 60),    // Darker status bar
-		StatusForeground: tcell.ColorBlack,                 // Black text for status
-		StatusIconColor:  tcell.NewRGBColor(147, 197, 253), // Light blue for icons
-
-		// Default dialog colors
-		DialogBackground:         tcell.NewRGBColor(40, 45, 55),    // Dark dialog bg
-		DialogForeground:         tcell.NewRGBColor(230, 230, 230), // Light text
-		DialogBorderColor:        tcell.NewRGBColor(80, 90, 110),   // Dark border
-		DialogButtonBackground:   tcell.NewRGBColor(70, 100, 170),  // Blue button bg
-		DialogButtonForeground:   tcell.NewRGBColor(240, 240, 240), // White button text
-		DialogSelectedBackground: tcell.NewRGBColor(100, 140, 210), // Bright blue selection
-		DialogSelectedForeground: tcell.NewRGBColor(255, 255, 255), // White selected text
-
-		// Default icons
-		IconSave:       '󰆓',
-		IconExit:       '󰅚',
-		IconFind:       '󰍉',
-		IconFile:       '󰈔',
-		IconModified:   '󰆓',
-		IconPosition:   '󰦪',
-		IconPercentage: '󰎚',
-	}
+	theme := defaultTheme()
 
 	// Get the theme filename from the main config
 	themePath, err := getThemePathFromConfig(configPath)
@@ -95,9 +79,38 @@ func LoadTheme(configPath string) (*Theme, error) {
 		fmt.Fprintln(os.Stderr, err)
 		// If we can't read the config, use the default theme path
 		// Ensure we use a path relative to the application
-		themePath = filepath.Join("config", "themes", "theme.conf")
+		themePath = filepath.Join(filepath.Dir(configPath), "themes", "theme.conf")
 	}
 
+	return loadThemeFile(theme, themePath)
+}
+
+// LoadThemeFile loads the theme file at themePath, in place of the one
+// named in the main config file
+func LoadThemeFile(themePath string) (*Theme, error) {
+	return loadThemeFile(defaultTheme(), themePath)
+}
+
+// FindTheme returns the path of the theme called name: a file in the themes
+// directory next to the main config file, with or without its .conf
+// extension, or else a path to a theme file
+func FindTheme(configPath, name string) (string, error) {
+	themesDir := filepath.Join(filepath.Dir(configPath), "themes")
+	for _, path := range []string{
+		filepath.Join(themesDir, name),
+		filepath.Join(themesDir, name+".conf"),
+		name,
+	} {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("theme '%s' not found in '%s'", name, themesDir)
+}
+
+// loadThemeFile reads the colors and icons in themePath over those already
+// in theme
+func loadThemeFile(theme *Theme, themePath string) (*Theme, error) {
 	// Try to open the theme file
 	file, err := os.Open(themePath)
 	if err != nil {
@@ -183,6 +196,16 @@ func LoadTheme(configPath string) (*Theme, error) {
 			theme.TextColor = color
 		case "cursor":
 			theme.CursorColor = color
+		case "line_number":
+			theme.LineNumberColor = color
+		case "current_line_number":
+			theme.CurrentLineNumberColor = color
+		case "current_line_bg":
+			theme.CurrentLineBackground = color
+		case "selection_bg":
+			theme.SelectionBackground = color
+		case "selection_fg":
+			theme.SelectionForeground = color
 		case "status_bg":
 			theme.StatusBackground = color
 		case "status_fg":
@@ -216,13 +239,51 @@ func LoadTheme(configPath string) (*Theme, error) {
 	return theme, nil
 }
 
+// defaultTheme returns the colors and icons used where a theme file sets
+// none
+func defaultTheme() *Theme {
+	return &Theme{
+		BackgroundColor:  tcell.NewRGBColor(40, 44, 52),    // Dark background
+		TextColor:        tcell.NewRGBColor(220, 223, 228), // Light text
+		CursorColor:      tcell.NewRGBColor(255, 165, 0),   // Orange cursor
+		StatusBackground: tcell.NewRGBColor(45, 50, 60),    // Darker status bar
+		StatusForeground: tcell.ColorBlack,                 // Black text for status
+		StatusIconColor:  tcell.NewRGBColor(147, 197, 253), // Light blue for icons
+
+		// Default gutter and current line colors
+		LineNumberColor:        tcell.NewRGBColor(90, 98, 112),   // Dim line numbers
+		CurrentLineNumberColor: tcell.NewRGBColor(220, 223, 228), // Bright current number
+		CurrentLineBackground:  tcell.NewRGBColor(44, 49, 60),    // Subtle current line
+
+		// Default selection colors
+		SelectionBackground: tcell.NewRGBColor(68, 85, 120),   // Muted blue selection
+		SelectionForeground: tcell.NewRGBColor(240, 240, 245), // Bright selected text
+
+		// Default dialog colors
+		DialogBackground:         tcell.NewRGBColor(40, 45, 55),    // Dark dialog bg
+		DialogForeground:         tcell.NewRGBColor(230, 230, 230), // Light text
+		DialogBorderColor:        tcell.NewRGBColor(80, 90, 110),   // Dark border
+		DialogButtonBackground:   tcell.NewRGBColor(70, 100, 170),  // Blue button bg
+		DialogButtonForeground:   tcell.NewRGBColor(240, 240, 240), // White button text
+		DialogSelectedBackground: tcell.NewRGBColor(100, 140, 210), // Bright blue selection
+		DialogSelectedForeground: tcell.NewRGBColor(255, 255, 255), // White selected text
+
+		// Default icons
+		IconSave:       '󰆓',
+		IconExit:       '󰅚',
+		IconFind:       '󰍉',
+		IconFile:       '󰈔',
+		IconModified:   '󰆓',
+		IconPosition:   '󰦪',
+		IconPercentage: '󰎚',
+	}
+}
+
 // getThemePathFromConfig reads the main config file to determine which theme to use
 func getThemePathFromConfig(configPath string) (string, error) {
-	// Always use paths relative to the application
-	configDir := "config"
-
-	// Path to the main config file - always use local config.conf
-	mainConfigPath := filepath.Join(configDir, "config.conf")
+	// Themes live in a directory next to the main config file
+	configDir := filepath.Dir(configPath)
+	mainConfigPath := configPath
 
 	// Default theme path
 	defaultThemePath := filepath.Join(configDir, "themes", "theme.conf")
@@ -260,7 +321,7 @@ func getThemePathFromConfig(configPath string) (string, error) {
 
 		// Look for the theme setting
 		if key == "theme" {
-			// Build the path to the theme file, always using local config
+			// Build the path to the theme file in the themes directory
 			themePath := filepath.Join(configDir, "themes", value)
 
 			// Verify the theme file exists
@@ -0,0 +1,218 @@
+// Package diff compares two texts line by line and formats the differences
+// as a unified diff.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind says whether a line is kept, removed or added
+type Kind int
+
+const (
+	Equal Kind = iota
+	Delete
+	Insert
+)
+
+// Op is one line of an edit script
+type Op struct {
+	Kind Kind
+	Line string
+}
+
+// Lines returns the shortest edit script turning a into b, using the linear
+// space variant of Myers' algorithm, so memory grows with the length of the
+// inputs rather than with the number of differences between them.
+func Lines(a, b []string) []Op {
+	return appendDiff(make([]Op, 0, max(len(a), len(b))), a, b)
+}
+
+// appendDiff appends the edit script turning a into b to ops. Lines shared
+// at the start and end are matched up front, which keeps the search small
+// for the usual case of a few local changes. What is left is split at the
+// middle snake of a shortest path, and each side is compared in turn.
+func appendDiff(ops []Op, a, b []string) []Op {
+	// Common prefix
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, Op{Equal, a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+
+	// Common suffix, kept for the end
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			ops = append(ops, Op{Insert, line})
+		}
+	case len(b) == 0:
+		for _, line := range a {
+			ops = append(ops, Op{Delete, line})
+		}
+	default:
+		x, y, u, v := middleSnake(a, b)
+		ops = appendDiff(ops, a[:x], b[:y])
+		for _, line := range a[x:u] {
+			ops = append(ops, Op{Equal, line})
+		}
+		ops = appendDiff(ops, a[u:], b[v:])
+	}
+
+	for _, line := range common {
+		ops = append(ops, Op{Equal, line})
+	}
+	return ops
+}
+
+// middleSnake finds the run of matching lines, from a[x], b[y] to a[u], b[v],
+// in the middle of a shortest edit script between a and b. Paths are
+// followed forward from the start and backward from the end at once until
+// they meet. forward[k] holds the furthest x reached on diagonal k = x - y,
+// backward[k] the furthest distance from the end on diagonal k counted
+// from the end. a and b must differ in their first and last lines.
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	limit := (n + m + 1) / 2
+	offset := limit + 1
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1] // step down: insert from b
+			} else {
+				x = forward[offset+k-1] + 1 // step right: delete from a
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			// The backward paths have taken d-1 steps
+			if back := delta - k; odd && back >= -(d-1) && back <= d-1 &&
+				x+backward[offset+back] >= n {
+				return startX, startY, x, y
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			if ahead := delta - k; !odd && ahead >= -d && ahead <= d &&
+				x+forward[offset+ahead] >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+
+	// Unreachable: the paths meet within (n+m+1)/2 steps
+	return 0, 0, 0, 0
+}
+
+// Unified formats the differences between a and b as a unified diff with
+// the given number of context lines around each change. It returns an
+// empty string when the texts are the same.
+func Unified(fromName, toName string, a, b []string, context int) string {
+	ops := Lines(a, b)
+
+	// Position of each op in a and b, counted from 1
+	aLine := make([]int, len(ops))
+	bLine := make([]int, len(ops))
+	ai, bi := 1, 1
+	var changes []int
+	for i, op := range ops {
+		aLine[i], bLine[i] = ai, bi
+		if op.Kind != Insert {
+			ai++
+		}
+		if op.Kind != Delete {
+			bi++
+		}
+		if op.Kind != Equal {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Group changes with no more than twice the context between them into
+	// one hunk, as their hunks would otherwise meet
+	for c := 0; c < len(changes); {
+		start := max(changes[c]-context, 0)
+		last := c
+		for last+1 < len(changes) && changes[last+1]-changes[last]-1 <= 2*context {
+			last++
+		}
+		end := min(changes[last]+context+1, len(ops))
+
+		aCount, bCount := 0, 0
+		for _, op := range ops[start:end] {
+			if op.Kind != Insert {
+				aCount++
+			}
+			if op.Kind != Delete {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", hunkStart(aLine[start], aCount), aCount, hunkStart(bLine[start], bCount), bCount)
+
+		for _, op := range ops[start:end] {
+			switch op.Kind {
+			case Equal:
+				sb.WriteString(" ")
+			case Delete:
+				sb.WriteString("-")
+			case Insert:
+				sb.WriteString("+")
+			}
+			sb.WriteString(op.Line)
+			sb.WriteString("\n")
+		}
+
+		c = last + 1
+	}
+
+	return sb.String()
+}
+
+// hunkStart returns the line number shown for a hunk; an empty range is
+// numbered by the line before it
+func hunkStart(line, count int) int {
+	if count == 0 {
+		return line - 1
+	}
+	return line
+}
@@ -0,0 +1,158 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// split turns text into lines the way the editor does, so text without a
+// trailing newline has no empty last line
+func split(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Op
+	}{
+		{
+			name: "empty",
+			a:    "",
+			b:    "",
+			want: []Op{},
+		},
+		{
+			name: "empty to text",
+			a:    "",
+			b:    "one\ntwo",
+			want: []Op{{Insert, "one"}, {Insert, "two"}},
+		},
+		{
+			name: "text to empty",
+			a:    "one\ntwo",
+			b:    "",
+			want: []Op{{Delete, "one"}, {Delete, "two"}},
+		},
+		{
+			name: "identical",
+			a:    "one\ntwo",
+			b:    "one\ntwo",
+			want: []Op{{Equal, "one"}, {Equal, "two"}},
+		},
+		{
+			name: "last line changed without trailing newline",
+			a:    "one\ntwo",
+			b:    "one\n2",
+			want: []Op{{Equal, "one"}, {Delete, "two"}, {Insert, "2"}},
+		},
+		{
+			name: "trailing newline added",
+			a:    "one\ntwo",
+			b:    "one\ntwo\n",
+			want: []Op{{Equal, "one"}, {Equal, "two"}, {Insert, ""}},
+		},
+		{
+			name: "changes in the middle",
+			a:    "a\nb\nc\nd\ne",
+			b:    "a\nx\nc\ne\nf",
+			want: []Op{{Equal, "a"}, {Delete, "b"}, {Insert, "x"}, {Equal, "c"},
+				{Delete, "d"}, {Equal, "e"}, {Insert, "f"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Lines(split(test.a), split(test.b))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Lines(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+			}
+		})
+	}
+}
+
+func TestLinesLargeDifference(t *testing.T) {
+	a := make([]string, 3000)
+	b := make([]string, 3000)
+	for i := range a {
+		a[i] = strings.Repeat("a", i%7)
+		b[i] = strings.Repeat("a", i%5)
+	}
+
+	var from, to []string
+	for _, op := range Lines(a, b) {
+		if op.Kind != Insert {
+			from = append(from, op.Line)
+		}
+		if op.Kind != Delete {
+			to = append(to, op.Line)
+		}
+	}
+	if !reflect.DeepEqual(from, a) || !reflect.DeepEqual(to, b) {
+		t.Fatal("edit script does not turn a into b")
+	}
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{
+			name:    "empty",
+			context: 3,
+			want:    "",
+		},
+		{
+			name:    "identical",
+			a:       "one\ntwo\nthree",
+			b:       "one\ntwo\nthree",
+			context: 3,
+			want:    "",
+		},
+		{
+			name:    "empty to text",
+			b:       "one",
+			context: 3,
+			want:    "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+one\n",
+		},
+		{
+			name:    "last line changed without trailing newline",
+			a:       "one\ntwo\nthree",
+			b:       "one\ntwo\n3",
+			context: 1,
+			want:    "--- a\n+++ b\n@@ -2,2 +2,2 @@\n two\n-three\n+3\n",
+		},
+		{
+			name:    "changes close together share a hunk",
+			a:       "1\n2\n3\n4\n5\n6\n7\n8",
+			b:       "1\nX\n3\n4\n5\n6\nY\n8",
+			context: 2,
+			want:    "--- a\n+++ b\n@@ -1,8 +1,8 @@\n 1\n-2\n+X\n 3\n 4\n 5\n 6\n-7\n+Y\n 8\n",
+		},
+		{
+			name:    "changes far apart get their own hunks",
+			a:       "1\n2\n3\n4\n5\n6\n7\n8\n9\n10",
+			b:       "1\nX\n3\n4\n5\n6\n7\n8\nY\n10",
+			context: 1,
+			want: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n 1\n-2\n+X\n 3\n" +
+				"@@ -8,3 +8,3 @@\n 8\n-9\n+Y\n 10\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Unified("a", "b", split(test.a), split(test.b), test.context)
+			if got != test.want {
+				t.Errorf("Unified() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
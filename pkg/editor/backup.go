@@ -0,0 +1,159 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pow/pkg/config"
+)
+
+// backupFile copies the version of filePath on disk aside before it is
+// overwritten, as set by the backup settings. A simple backup is the file
+// name with "~" appended; numbered backups are named "name.~N~", counting
+// up, and only the newest ones are kept. Backups go next to the file unless
+// a backup directory is set. Nothing is done for a file that doesn't exist
+// yet.
+func backupFile(filePath string, settings *config.Settings) error {
+	if settings.Backup == config.BackupOff {
+		return nil
+	}
+
+	// Back up the file a symlink points at, which is what gets overwritten
+	target, err := filepath.EvalSymlinks(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("cannot resolve '%s': %w", filePath, err)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return fmt.Errorf("cannot access '%s': %w", target, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	dir, name, err := backupLocation(target, settings.BackupDir)
+	if err != nil {
+		return err
+	}
+
+	if settings.Backup == config.BackupSimple {
+		return copyFile(target, filepath.Join(dir, name+"~"), info.Mode().Perm())
+	}
+
+	// Numbered backups continue from the highest number found
+	numbers, err := backupNumbers(dir, name)
+	if err != nil {
+		return err
+	}
+	next := 1
+	if len(numbers) > 0 {
+		next = numbers[len(numbers)-1] + 1
+	}
+	if err := copyFile(target, filepath.Join(dir, numberedBackupName(name, next)), info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	// Drop the oldest backups beyond the retention count
+	numbers = append(numbers, next)
+	for _, n := range numbers[:max(len(numbers)-settings.BackupCount, 0)] {
+		os.Remove(filepath.Join(dir, numberedBackupName(name, n)))
+	}
+	return nil
+}
+
+// backupLocation returns the directory backups of target go in and the
+// name they are based on. In a shared backup directory, the name is the
+// file's full path with each separator replaced by '%', so files of the same
+// name in different directories don't overwrite each other's backups.
+func backupLocation(target, backupDir string) (string, string, error) {
+	if backupDir == "" {
+		dir, name := filepath.Split(target)
+		if dir == "" {
+			dir = "."
+		}
+		return dir, name, nil
+	}
+
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return "", "", fmt.Errorf("cannot create backup directory '%s': %w", backupDir, err)
+	}
+
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot resolve '%s': %w", target, err)
+	}
+	return backupDir, strings.ReplaceAll(abs, string(filepath.Separator), "%"), nil
+}
+
+// numberedBackupName returns the name of backup n of a file
+func numberedBackupName(name string, n int) string {
+	return fmt.Sprintf("%s.~%d~", name, n)
+}
+
+// backupNumbers returns the numbers of the existing numbered backups of a
+// file in dir, in ascending order
+func backupNumbers(dir, name string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read backup directory '%s': %w", dir, err)
+	}
+
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(name) + `\.~([0-9]+)~$`)
+	var numbers []int
+	for _, entry := range entries {
+		if m := pattern.FindStringSubmatch(entry.Name()); m != nil {
+			if n, err := strconv.Atoi(m[1]); err == nil {
+				numbers = append(numbers, n)
+			}
+		}
+	}
+
+	// Directory entries are sorted by name, which puts 10 before 9
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// copyFile copies src to dst with the given permissions. The copy is
+// written to a temporary file first so an existing dst is only replaced
+// by a complete one.
+func copyFile(src, dst string, perm os.FileMode) error {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("cannot read '%s': %w", src, err)
+	}
+
+	dir, base := filepath.Split(dst)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot create backup in '%s': %w", dir, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot write '%s': %w", tmp.Name(), err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot set permissions on '%s': %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot close '%s': %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return fmt.Errorf("cannot write backup '%s': %w", dst, err)
+	}
+	return nil
+}
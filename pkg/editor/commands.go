@@ -0,0 +1,255 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// command is a named action that keys are bound to. The names are used in
+// the [keys] section of the config file.
+type command struct {
+	name        string
+	description string
+	keys        []string // bound unless the config file says otherwise
+	movement    bool     // only moves the cursor, so Shift extends the selection
+	run         func(e *Editor)
+}
+
+// commands lists every action that can be bound to a key. It is filled in
+// by init, as the commands refer back to the editor methods that use it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"quit", "Exit, asking to save each modified buffer", []string{"ctrl+x"}, false, func(e *Editor) { e.shutdown(true) }},
+		{"help", "Show every command and its keys", []string{"ctrl+g"}, false, (*Editor).showHelp},
+		{"palette", "Search for a command and run it", []string{"ctrl+p", "f1"}, false, (*Editor).commandPalette},
+		{"save", "Save the current buffer", []string{"ctrl+s"}, false, (*Editor).saveFile},
+		{"find", "Search the buffer", []string{"ctrl+f"}, false, (*Editor).enterSearchMode},
+		{"replace", "Search and replace", []string{"ctrl+r", "ctrl+\\"}, false, (*Editor).enterReplaceMode},
+		{"goto-line", "Go to a line, or a line and column", []string{"ctrl+_", "ctrl+l"}, false, (*Editor).enterGotoMode},
+		{"copy", "Copy the selection", []string{"ctrl+c"}, false, (*Editor).copyToClipboard},
+		{"cut", "Cut the selection or the current line", []string{"ctrl+k"}, false, (*Editor).cutToClipboard},
+		{"paste", "Paste from the clipboard", []string{"ctrl+v"}, false, (*Editor).pasteFromClipboard},
+		{"undo", "Undo the last change", []string{"ctrl+z"}, false, func(e *Editor) { e.clearSelection(); e.undo() }},
+		{"redo", "Redo the last undone change", []string{"ctrl+y"}, false, func(e *Editor) { e.clearSelection(); e.redo() }},
+		{"buffers", "Pick an open buffer", []string{"ctrl+b"}, false, (*Editor).pickDocument},
+		{"next-buffer", "Switch to the next buffer", []string{"alt+."}, false, func(e *Editor) { e.cycleDocument(1) }},
+		{"prev-buffer", "Switch to the previous buffer", []string{"alt+,"}, false, func(e *Editor) { e.cycleDocument(-1) }},
+		{"next-pane", "Move to the next pane", []string{"ctrl+o"}, false, func(e *Editor) { e.cyclePane(1) }},
+		{"split-below", "Split the pane with the new pane below", []string{"alt+h"}, false, func(e *Editor) { e.splitPane(false) }},
+		{"split-right", "Split the pane with the new pane to the right", []string{"alt+v"}, false, func(e *Editor) { e.splitPane(true) }},
+		{"close-pane", "Close the current pane", []string{"alt+q"}, false, (*Editor).closePane},
+		{"soft-wrap", "Turn soft wrap on or off", []string{"alt+s"}, false, (*Editor).toggleSoftWrap},
+		{"shortcut-bar", "Show or hide the shortcut bar", nil, false, (*Editor).toggleShortcutBar},
+		{"line-ending", "Switch between LF, CRLF and CR line endings", []string{"alt+l"}, false, (*Editor).cycleLineEnding},
+		{"bom", "Add or remove the byte order mark", []string{"alt+b"}, false, (*Editor).toggleBOM},
+		{"cursor-up", "Move up a line", []string{"up"}, true, (*Editor).cursorUp},
+		{"cursor-down", "Move down a line", []string{"down"}, true, (*Editor).cursorDown},
+		{"cursor-left", "Move left a character", []string{"left"}, true, (*Editor).cursorLeft},
+		{"cursor-right", "Move right a character", []string{"right"}, true, (*Editor).cursorRight},
+		{"page-up", "Move up a page", []string{"pgup"}, true, (*Editor).pageUp},
+		{"page-down", "Move down a page", []string{"pgdn"}, true, (*Editor).pageDown},
+		{"line-start", "Move to the start of the line", []string{"home"}, true, func(e *Editor) { e.cursorX = 0 }},
+		{"line-end", "Move to the end of the line", []string{"end"}, true, (*Editor).cursorLineEnd},
+		{"newline", "Start a new line", []string{"enter"}, false, func(e *Editor) { e.insertAtCursor("\n", groupNone) }},
+		{"backspace", "Delete the character before the cursor", []string{"backspace"}, false, (*Editor).backspace},
+		{"delete", "Delete the character under the cursor", []string{"delete"}, false, (*Editor).deleteForward},
+		{"indent", "Insert a tab, or spaces to the next tab stop", []string{"tab"}, false, (*Editor).insertTab},
+	}
+}
+
+// editingCommands change the buffer or write it out, so they are refused in
+// a read-only buffer
+var editingCommands = map[string]bool{
+	"save": true, "replace": true, "cut": true, "paste": true, "undo": true, "redo": true,
+	"line-ending": true, "bom": true, "newline": true, "backspace": true, "delete": true, "indent": true,
+}
+
+// runCommand runs cmd, unless it would change a read-only buffer
+func (e *Editor) runCommand(cmd *command) {
+	if editingCommands[cmd.name] && !e.checkWritable() {
+		return
+	}
+	cmd.run(e)
+}
+
+// checkWritable reports whether the current buffer may be changed, telling
+// the user why not when it can't
+func (e *Editor) checkWritable() bool {
+	if e.readOnly {
+		e.showMessage(fmt.Sprintf("%s is read-only", e.name()))
+		return false
+	}
+	return true
+}
+
+// findCommand returns the command with the given name, or nil
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// stopped reports whether the editor has shut down
+func (e *Editor) stopped() bool {
+	select {
+	case <-e.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// heldKeySpeed returns how many lines a held Up or Down key moves at once,
+// speeding up the longer it is held as tracked by keyCounter
+func (e *Editor) heldKeySpeed() int {
+	switch {
+	case e.keyCounter > 15:
+		return 10
+	case e.keyCounter > 10:
+		return 5
+	case e.keyCounter > 5:
+		return 3
+	}
+	return 1
+}
+
+// cursorUp moves the cursor up, by screen rows when lines are wrapped
+func (e *Editor) cursorUp() {
+	moveAmount := e.heldKeySpeed()
+	if e.softWrap {
+		e.moveVisualRows(-moveAmount)
+	} else {
+		// Keep the cursor in the same screen column where possible
+		e.moveCursorToLine(max(e.cursorY-moveAmount, 0))
+	}
+
+	// Update scroll position to keep cursor in view
+	e.ensureVisibleCursor()
+}
+
+// cursorDown moves the cursor down, by screen rows when lines are wrapped
+func (e *Editor) cursorDown() {
+	moveAmount := e.heldKeySpeed()
+	if e.softWrap {
+		e.moveVisualRows(moveAmount)
+	} else {
+		// Keep the cursor in the same screen column where possible, going no
+		// further than the extra line
+		e.moveCursorToLine(min(e.cursorY+moveAmount, e.lineCount()))
+	}
+
+	// Update scroll position to keep cursor in view
+	e.ensureVisibleCursor()
+}
+
+// cursorLeft moves the cursor back a character, to the end of the previous
+// line at the start of a line
+func (e *Editor) cursorLeft() {
+	if e.cursorX > 0 {
+		e.cursorX = prevGrapheme(e.line(e.cursorY), e.cursorX)
+	} else if e.cursorY > 0 {
+		e.cursorY--
+		e.cursorX = e.lineLen(e.cursorY)
+	}
+}
+
+// cursorRight moves the cursor forward a character, to the start of the next
+// line at the end of a line
+func (e *Editor) cursorRight() {
+	if e.cursorY < e.lineCount() && e.cursorX < e.lineLen(e.cursorY) {
+		e.cursorX = nextGrapheme(e.line(e.cursorY), e.cursorX)
+	} else if e.cursorY < e.lineCount() {
+		e.cursorY++
+		e.cursorX = 0
+	}
+}
+
+// pageUp moves the cursor up by the height of the pane, by screen rows when
+// wrapping
+func (e *Editor) pageUp() {
+	if e.softWrap {
+		e.moveVisualRows(-e.height)
+	} else if e.cursorY > 0 {
+		e.moveCursorToLine(max(e.cursorY-e.height, 0))
+	}
+}
+
+// pageDown moves the cursor down by the height of the pane, by screen rows
+// when wrapping
+func (e *Editor) pageDown() {
+	if e.softWrap {
+		e.moveVisualRows(e.height)
+	} else if e.cursorY < e.lineCount()-1 {
+		e.moveCursorToLine(min(e.cursorY+e.height, e.lineCount()-1))
+	}
+}
+
+// cursorLineEnd moves the cursor to the end of the line
+func (e *Editor) cursorLineEnd() {
+	if e.cursorY < e.lineCount() {
+		e.cursorX = e.lineLen(e.cursorY)
+	}
+}
+
+// backspace deletes the selection or the character before the cursor,
+// joining the line to the previous one at its start
+func (e *Editor) backspace() {
+	// A selection is removed as a unit
+	if e.deleteSelection() {
+		return
+	}
+
+	if e.cursorY >= e.lineCount() {
+		// The extra line beyond content has nothing to merge, just move up
+		if e.cursorY > 0 {
+			e.cursorY = e.lineCount() - 1
+			e.cursorX = e.lineLen(e.cursorY)
+		}
+	} else if e.cursorX > 0 {
+		// Delete the character before the cursor
+		start := position{e.cursorY, prevGrapheme(e.line(e.cursorY), e.cursorX)}
+		e.deleteText(start, e.cursorPos(), groupBackspace)
+		e.setCursor(start)
+	} else if e.cursorY > 0 {
+		// We're at the beginning of a line, merge with the previous line
+		start := position{e.cursorY - 1, e.lineLen(e.cursorY - 1)}
+		e.deleteText(start, e.cursorPos(), groupBackspace)
+		e.setCursor(start)
+	}
+}
+
+// deleteForward deletes the selection or the character under the cursor,
+// joining the next line at the end of a line
+func (e *Editor) deleteForward() {
+	// A selection is removed as a unit
+	if e.deleteSelection() {
+		return
+	}
+
+	if e.cursorY < e.lineCount() {
+		currentLine := e.line(e.cursorY)
+		if e.cursorX < len(currentLine) {
+			// Delete character at cursor
+			end := position{e.cursorY, nextGrapheme(currentLine, e.cursorX)}
+			e.deleteText(e.cursorPos(), end, groupDelete)
+		} else if e.cursorY < e.lineCount()-1 {
+			// At the end of the line, merge with next line
+			e.deleteText(e.cursorPos(), position{e.cursorY + 1, 0}, groupDelete)
+		}
+	}
+}
+
+// insertTab inserts a tab character, or spaces up to the next tab stop
+func (e *Editor) insertTab() {
+	if e.expandTabs {
+		column := e.cursorColumn()
+		e.insertAtCursor(strings.Repeat(" ", e.tabWidth-column%e.tabWidth), groupTyping)
+	} else {
+		e.insertAtCursor("\t", groupTyping)
+	}
+}
@@ -0,0 +1,135 @@
+package editor
+
+import "github.com/gdamore/tcell/v2"
+
+// drawDialogFrame draws an empty dialog box with its top left corner at x, y:
+// a shadow, the background, a border and the title centred in the top edge
+func (e *Editor) drawDialogFrame(x, y, width, height int, title string) {
+	dialogStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogForeground).
+		Background(e.theme.DialogBackground)
+
+	borderStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogBorderColor).
+		Background(e.theme.DialogBackground)
+
+	titleStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogSelectedForeground).
+		Background(e.theme.DialogButtonBackground)
+
+	shadowStyle := tcell.StyleDefault.
+		Background(tcell.NewRGBColor(10, 10, 10)).
+		Foreground(tcell.NewRGBColor(10, 10, 10))
+
+	// Shadow along the right and bottom edges, offset from the box
+	for row := y + 1; row <= y+height; row++ {
+		for col := x + 2; col <= x+width+1; col++ {
+			if row == y+height || col == x+width+1 {
+				e.screen.SetContent(col, row, ' ', nil, shadowStyle)
+			}
+		}
+	}
+
+	// Background and border
+	for row := y; row < y+height; row++ {
+		for col := x; col < x+width; col++ {
+			e.screen.SetContent(col, row, ' ', nil, dialogStyle)
+		}
+	}
+	for col := x + 1; col < x+width-1; col++ {
+		e.screen.SetContent(col, y, '─', nil, borderStyle)
+		e.screen.SetContent(col, y+height-1, '─', nil, borderStyle)
+	}
+	for row := y + 1; row < y+height-1; row++ {
+		e.screen.SetContent(x, row, '│', nil, borderStyle)
+		e.screen.SetContent(x+width-1, row, '│', nil, borderStyle)
+	}
+	e.screen.SetContent(x, y, '┌', nil, borderStyle)
+	e.screen.SetContent(x+width-1, y, '┐', nil, borderStyle)
+	e.screen.SetContent(x, y+height-1, '└', nil, borderStyle)
+	e.screen.SetContent(x+width-1, y+height-1, '┘', nil, borderStyle)
+
+	e.drawCentred(x+1, x+width-1, y, " "+title+" ", titleStyle)
+}
+
+// askChoice shows a message with a row of buttons and returns the index of
+// the one chosen with Enter, or -1 if the dialog is dismissed with Esc
+func (e *Editor) askChoice(title, message string, options []string) int {
+	width, height := e.screen.Size()
+	selected := 0
+
+	// Dialog dimensions
+	dialogWidth := min(max(50, stringWidth(message)+8), width-4)
+	dialogHeight := 9
+	dialogX := (width - dialogWidth) / 2
+	dialogY := (height - dialogHeight) / 2
+
+	// Create styles
+	dialogStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogForeground).
+		Background(e.theme.DialogBackground)
+
+	buttonStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogButtonForeground).
+		Background(e.theme.DialogButtonBackground)
+
+	selectedStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogSelectedForeground).
+		Background(e.theme.DialogSelectedBackground)
+
+	for {
+		e.drawDialogFrame(dialogX, dialogY, dialogWidth, dialogHeight, title)
+		e.drawCentred(dialogX+1, dialogX+dialogWidth-1, dialogY+3, message, dialogStyle)
+
+		// Draw the buttons centred in a row
+		buttonY := dialogY + 5
+		totalButtonWidth := (len(options) - 1) * 3
+		for _, opt := range options {
+			totalButtonWidth += stringWidth(opt) + 4
+		}
+		buttonX := dialogX + (dialogWidth-totalButtonWidth)/2
+
+		for i, opt := range options {
+			buttonWidth := stringWidth(opt) + 4
+
+			style := buttonStyle
+			if i == selected {
+				style = selectedStyle
+			}
+
+			// Rounded button outline with the label in the middle row
+			e.screen.SetContent(buttonX, buttonY, '╭', nil, style)
+			e.screen.SetContent(buttonX+buttonWidth-1, buttonY, '╮', nil, style)
+			e.screen.SetContent(buttonX, buttonY+1, '│', nil, style)
+			e.screen.SetContent(buttonX+buttonWidth-1, buttonY+1, '│', nil, style)
+			e.screen.SetContent(buttonX, buttonY+2, '╰', nil, style)
+			e.screen.SetContent(buttonX+buttonWidth-1, buttonY+2, '╯', nil, style)
+			for x := buttonX + 1; x < buttonX+buttonWidth-1; x++ {
+				e.screen.SetContent(x, buttonY, '─', nil, style)
+				e.screen.SetContent(x, buttonY+1, ' ', nil, style)
+				e.screen.SetContent(x, buttonY+2, '─', nil, style)
+			}
+			e.drawText(buttonX+2, buttonY+1, opt, style)
+
+			buttonX += buttonWidth + 3
+		}
+
+		e.screen.Show()
+
+		// Handle input
+		ev := e.pollEvent()
+		switch ev := ev.(type) {
+		case *tcell.EventKey:
+			switch ev.Key() {
+			case tcell.KeyLeft:
+				selected = (selected + len(options) - 1) % len(options)
+			case tcell.KeyRight, tcell.KeyTab:
+				selected = (selected + 1) % len(options)
+			case tcell.KeyEnter:
+				return selected
+			case tcell.KeyEscape:
+				return -1
+			}
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gdamore/tcell/v2"
+
+	"pow/pkg/buffer"
+	"pow/pkg/config"
+	"pow/pkg/syntax"
+)
+
+// document is an open buffer together with its file and undo history
+type document struct {
+	filePath    string
+	buf         *buffer.Buffer
+	highlighter *syntax.Highlighter
+
+	// Line endings and byte order mark of the file, and as last saved
+	format      fileFormat
+	savedFormat fileFormat
+
+	// Version of the file on disk the buffer was loaded from or saved as,
+	// and the time and size of a changed version the user chose not to
+	// reload
+	stamp        fileStamp
+	ignoredStamp fileStamp
+
+	// Indentation settings
+	tabWidth   int
+	expandTabs bool
+
+	modified bool
+
+	// Refuse to change or save the buffer
+	readOnly bool
+
+	// Language to highlight the buffer as, whatever the file is called
+	language string
+
+	// Edits made to the buffer, the count when the swap file was last
+	// written, and whether this session wrote it
+	changes     int
+	swapChanges int
+	hasSwap     bool
+
+	// A swap file left by another session is kept, so this one writes none
+	swapKept bool
+
+	// Undo state
+	history    *history
+	savedState int // history state matching the file on disk, -1 if never saved
+
+	// Cursor and scroll position the document was last left with in a view
+	state viewState
+}
+
+// newDocument opens filePath in a new document. A path that does not exist
+// yet, or an empty path, gives an empty document that is saved on request.
+func newDocument(filePath string, settings *config.Settings) (*document, error) {
+	content := buffer.New("")
+	format := fileFormat{}
+	stamp := fileStamp{}
+	fileExists := true
+
+	// Check if a file path was provided
+	if filePath == "" {
+		fileExists = false
+		filePath = "untitled.txt" // Use a default filename but don't save yet
+	} else {
+		// Try to load the file if it exists
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			fileExists = false
+			fmt.Fprintln(os.Stderr, "New file:", filePath)
+		} else {
+			var err error
+			content, format, stamp, err = loadFile(filePath)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// A new file has no saved state to return to through undo
+	savedState := 0
+	if !fileExists {
+		savedState = -1
+	}
+
+	doc := &document{
+		filePath:    filePath,
+		buf:         content,
+		highlighter: syntax.NewHighlighter(filePath),
+		format:      format,
+		savedFormat: format,
+		stamp:       stamp,
+		modified:    !fileExists, // Mark as modified if it's a new file
+		history:     newHistory(),
+		savedState:  savedState,
+	}
+	doc.applyIndentStyle(settings)
+
+	return doc, nil
+}
+
+// resetHighlighter starts highlighting the document afresh, in the language
+// chosen for it or else the one its file name suggests
+func (d *document) resetHighlighter() {
+	d.highlighter = nil
+	if d.language != "" {
+		d.highlighter = syntax.NewHighlighterForLanguage(d.language)
+	}
+	if d.highlighter == nil {
+		d.highlighter = syntax.NewHighlighter(d.filePath)
+	}
+}
+
+// loadFile reads the content of a file into a buffer, along with the line
+// endings and byte order mark it was stored with and the stamp of the
+// version read
+func loadFile(filePath string) (*buffer.Buffer, fileFormat, fileStamp, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fileFormat{}, fileStamp{}, err
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fileFormat{}, fileStamp{}, err
+	}
+
+	text, format := decodeText(string(content))
+	return buffer.New(text), format, stampOf(info, content), nil
+}
+
+// clampPosition limits pos to a valid location inside the content
+func (d *document) clampPosition(pos position) position {
+	if pos.line < 0 {
+		return position{0, 0}
+	}
+	if pos.line >= d.buf.LineCount() {
+		last := d.buf.LineCount() - 1
+		return position{last, d.buf.LineLen(last)}
+	}
+	if pos.col < 0 {
+		pos.col = 0
+	}
+	if pos.col > d.buf.LineLen(pos.line) {
+		pos.col = d.buf.LineLen(pos.line)
+	}
+	return pos
+}
+
+// hardTabFileTypes lists file types whose tooling expects real tab characters
+var hardTabFileTypes = map[string]bool{
+	"Go":       true,
+	"Makefile": true,
+}
+
+// applyIndentStyle sets the tab settings for the document's file type
+func (d *document) applyIndentStyle(settings *config.Settings) {
+	d.tabWidth = settings.TabWidth
+	d.expandTabs = settings.ExpandTabs && !hardTabFileTypes[d.highlighter.GetFileType()]
+}
+
+// name returns the file name shown for the document
+func (d *document) name() string {
+	return filepath.Base(d.filePath)
+}
+
+// switchDocument shows the document at idx in the focused view, returning to
+// where it was last left. Search results belong to the document they were
+// found in and are dropped.
+func (e *Editor) switchDocument(idx int) {
+	if idx < 0 || idx >= len(e.documents) || e.documents[idx] == e.document {
+		return
+	}
+	if e.searchMode {
+		e.exitSearchMode()
+	}
+	e.searchResults = []SearchResult{}
+	e.currentSearchIdx = -1
+	e.document.state = e.viewState
+	e.document = e.documents[idx]
+	e.viewState = e.document.state
+
+	// Another view may have edited the document since
+	e.setCursor(e.clampPosition(e.cursorPos()))
+	e.anchor = e.clampPosition(e.anchor)
+}
+
+// documentIndex returns the position of the current document in the list
+func (e *Editor) documentIndex() int {
+	for idx, doc := range e.documents {
+		if doc == e.document {
+			return idx
+		}
+	}
+	return 0
+}
+
+// cycleDocument moves delta documents forward or back, wrapping around
+func (e *Editor) cycleDocument(delta int) {
+	n := len(e.documents)
+	e.switchDocument(((e.documentIndex()+delta)%n + n) % n)
+}
+
+// pickDocument shows the open buffers in a dialog and switches to the one
+// chosen with Enter. Modified buffers are marked.
+func (e *Editor) pickDocument() {
+	width, height := e.screen.Size()
+	selected := e.documentIndex()
+
+	// Dialog dimensions, one row per buffer
+	dialogWidth := min(60, width-4)
+	listHeight := min(len(e.documents), max(height-8, 1))
+	dialogHeight := listHeight + 4
+	dialogX := (width - dialogWidth) / 2
+	dialogY := (height - dialogHeight) / 2
+
+	// Create styles
+	dialogStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogForeground).
+		Background(e.theme.DialogBackground)
+
+	selectedStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogSelectedForeground).
+		Background(e.theme.DialogSelectedBackground)
+
+	scroll := 0
+
+	for {
+		// Keep the selected buffer within the visible rows
+		if selected < scroll {
+			scroll = selected
+		} else if selected >= scroll+listHeight {
+			scroll = selected - listHeight + 1
+		}
+
+		e.drawDialogFrame(dialogX, dialogY, dialogWidth, dialogHeight, "Buffers")
+
+		// Draw the buffer list
+		for row := 0; row < listHeight; row++ {
+			idx := scroll + row
+			if idx >= len(e.documents) {
+				break
+			}
+			doc := e.documents[idx]
+
+			style := dialogStyle
+			if idx == selected {
+				style = selectedStyle
+			}
+
+			marker := ' '
+			if doc.modified {
+				marker = e.theme.IconModified
+			}
+
+			y := dialogY + 2 + row
+			for x := dialogX + 2; x < dialogX+dialogWidth-2; x++ {
+				e.screen.SetContent(x, y, ' ', nil, style)
+			}
+			entry := fmt.Sprintf("%d %c %s", idx+1, marker, doc.filePath)
+			e.drawTextClipped(dialogX+3, y, dialogX+dialogWidth-3, entry, style)
+		}
+
+		e.screen.Show()
+
+		// Handle input
+		ev := e.pollEvent()
+		switch ev := ev.(type) {
+		case *tcell.EventKey:
+			switch ev.Key() {
+			case tcell.KeyUp:
+				selected = (selected + len(e.documents) - 1) % len(e.documents)
+			case tcell.KeyDown:
+				selected = (selected + 1) % len(e.documents)
+			case tcell.KeyEnter:
+				e.switchDocument(selected)
+				return
+			case tcell.KeyEscape:
+				return
+			}
+		}
+	}
+}
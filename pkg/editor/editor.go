@@ -1,90 +1,134 @@
 package editor
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"runtime"
+	"regexp"
+	regexpsyntax "regexp/syntax"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gdamore/tcell/v2"
 
+	"pow/pkg/clipboard"
 	"pow/pkg/config"
 	"pow/pkg/syntax"
 )
 
 // Editor represents the text editor application
 type Editor struct {
-	screen      tcell.Screen
-	filePath    string
-	content     []string
-	theme       *config.Theme
-	highlighter *syntax.Highlighter
-
-	// Editing state
-	cursorX  int
-	cursorY  int
-	scrollY  int // Track vertical scroll position
-	modified bool
-	quit     chan struct{}
+	screen    tcell.Screen
+	theme     *config.Theme
+	settings  *config.Settings
+	clipboard *clipboard.Clipboard
+	keymap    keymap
+	quit      chan struct{}
+
+	// Open buffers
+	documents []*document
+
+	// Panes on screen; the focused view is embedded so its document and
+	// editing state are reached directly through the editor
+	*view
+	root *pane
+
+	// Wrap long lines onto several rows instead of scrolling sideways
+	softWrap bool
+
+	// Show the two rows of shortcuts below the status line
+	shortcutBar bool
 
 	// Search state
 	searchMode       bool
 	searchQuery      string
 	searchResults    []SearchResult
 	currentSearchIdx int
-
-	// Key counter for cursor movement
-	keyCounter int
+	searchPattern    *regexp.Regexp
+	searchErr        string // why the query is not a valid pattern
+
+	// Search options, toggled from the search bar
+	searchCaseSensitive bool
+	searchWholeWord     bool
+	searchRegex         bool
+
+	// Replace state
+	replaceStage   replaceStage
+	replaceText    string
+	replaceOrigin  position // cursor position when replace started
+	replaceCount   int      // matches replaced so far
+	replaceVisited int      // matches replaced or skipped so far
+	replaceTotal   int      // matches found when confirmation started
+
+	// Go to line prompt state
+	gotoMode  bool
+	gotoInput string
+	gotoErr   string // why the line typed can't be read
+
+	// Key counter for accelerating held cursor movement
+	keyCounter  int
+	lastKey     tcell.Key
+	lastKeyTime time.Time
 }
 
+// Markers drawn at the edge of a pane when a line is clipped
+const (
+	clippedLeft  = '‹'
+	clippedRight = '›'
+)
+
+// keyRepeatInterval is the longest gap between two presses of the same key
+// that still counts as the key being held down
+const keyRepeatInterval = 100 * time.Millisecond
+
 // SearchResult represents a found match
 type SearchResult struct {
 	Line int
 	Col  int
 	Len  int
-}
 
-// NewEditor creates a new editor instance
-func NewEditor(filePath string) (*Editor, error) {
-	var content []string
-	fileExists := true
+	// Start and end of each capture group counted from Col, or -1 for a
+	// group that took no part, kept to expand a regex replacement
+	Groups []int
+}
 
-	// Check if a file path was provided
-	if filePath == "" {
-		content = []string{""}
-		fileExists = false
-		filePath = "untitled.txt" // Use a default filename but don't save yet
-	} else {
-		// Try to load the file if it exists
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			fileExists = false
-			content = []string{""}
-			fmt.Fprintln(os.Stderr, "New file")
-		} else {
-			var err error
-			content, err = loadFile(filePath)
-			if err != nil {
-				return nil, err
-			}
-		}
+// NewEditor creates a new editor instance with a buffer for each file path.
+// With no paths, a single untitled buffer is opened. A path may be preceded
+// by "+line" or followed by ":line:column" to open the file at that line.
+func NewEditor(options Options, args ...string) (*Editor, error) {
+	if err := options.Validate(args...); err != nil {
+		return nil, err
 	}
-
-	// Ensure we always have at least one line
-	if len(content) == 0 {
-		content = []string{""}
+	filePaths, targets, err := parseFileArgs(options.fileArgs(args))
+	if err != nil {
+		return nil, err
+	}
+	if len(filePaths) == 0 {
+		filePaths = []string{""}
+		targets = []*lineTarget{nil}
 	}
 
-	// Load theme configuration
-	// Always use local config directory, make sure we check if it exists
-	configPath := filepath.Join("config", "config.conf")
+	// Load theme configuration, from the config directory unless another
+	// config file was given
+	configPath := options.configPath()
 
 	// Ensure we have a valid theme even if loading fails
 	var theme *config.Theme
 
 	// Try to load the theme, handle any errors
-	theme, themeErr := config.LoadTheme(configPath)
+	var themeErr error
+	if options.Theme != "" {
+		themePath, err := config.FindTheme(configPath, options.Theme)
+		if err != nil {
+			return nil, err
+		}
+		theme, themeErr = config.LoadThemeFile(themePath)
+	} else {
+		theme, themeErr = config.LoadTheme(configPath)
+	}
 	if themeErr != nil {
 		// Just print the error, don't abort - we'll use the default theme
 		fmt.Fprintln(os.Stderr, "Theme loading error:", themeErr)
@@ -99,6 +143,15 @@ func NewEditor(filePath string) (*Editor, error) {
 				StatusForeground: tcell.ColorBlack,                 // Black text for status
 				StatusIconColor:  tcell.NewRGBColor(147, 197, 253), // Light blue for icons
 
+				// Default gutter and current line colors
+				LineNumberColor:        tcell.NewRGBColor(90, 98, 112),   // Dim line numbers
+				CurrentLineNumberColor: tcell.NewRGBColor(220, 223, 228), // Bright current number
+				CurrentLineBackground:  tcell.NewRGBColor(44, 49, 60),    // Subtle current line
+
+				// Default selection colors
+				SelectionBackground: tcell.NewRGBColor(68, 85, 120),   // Muted blue selection
+				SelectionForeground: tcell.NewRGBColor(240, 240, 245), // Bright selected text
+
 				// Default dialog colors
 				DialogBackground:         tcell.NewRGBColor(40, 45, 55),    // Dark dialog bg
 				DialogForeground:         tcell.NewRGBColor(230, 230, 230), // Light text
@@ -120,6 +173,34 @@ func NewEditor(filePath string) (*Editor, error) {
 		}
 	}
 
+	// Load editor settings, falling back to the defaults on error
+	// Problems with the settings and key bindings are shown once the screen
+	// is up
+	settings, problems, settingsErr := config.LoadSettings(configPath)
+	if settingsErr != nil {
+		problems = append(problems, fmt.Sprintf("Settings loading error: %v", settingsErr))
+	}
+
+	// Bind keys to commands
+	keys, keyProblems := newKeymap(settings.Bindings, configPath)
+	problems = append(problems, keyProblems...)
+
+	// Open a buffer for each file
+	documents := make([]*document, 0, len(filePaths))
+	for _, filePath := range filePaths {
+		doc, err := newDocument(filePath, settings)
+		if err != nil {
+			return nil, err
+		}
+		doc.readOnly = options.ReadOnly
+		if options.Syntax != "" {
+			doc.language = options.Syntax
+			doc.resetHighlighter()
+			doc.applyIndentStyle(settings)
+		}
+		documents = append(documents, doc)
+	}
+
 	// Initialize screen
 	screen, err := tcell.NewScreen()
 	if err != nil {
@@ -130,21 +211,22 @@ func NewEditor(filePath string) (*Editor, error) {
 		return nil, err
 	}
 
-	// Initialize syntax highlighter
-	highlighter := syntax.NewHighlighter(filePath)
+	// Start with a single pane showing the first buffer
+	focus := &view{document: documents[0]}
 
 	// Create editor instance
 	editor := &Editor{
 		screen:           screen,
-		filePath:         filePath,
-		content:          content,
 		theme:            theme,
-		highlighter:      highlighter,
-		cursorX:          0,
-		cursorY:          0,
-		scrollY:          0,
-		modified:         !fileExists, // Mark as modified if it's a new file
+		settings:         settings,
+		clipboard:        clipboard.New(screen.SetClipboard),
+		keymap:           keys,
 		quit:             make(chan struct{}),
+		documents:        documents,
+		view:             focus,
+		root:             &pane{view: focus},
+		softWrap:         settings.SoftWrap,
+		shortcutBar:      settings.ShortcutBar,
 		searchMode:       false,
 		searchQuery:      "",
 		searchResults:    []SearchResult{},
@@ -152,29 +234,81 @@ func NewEditor(filePath string) (*Editor, error) {
 		keyCounter:       0,
 	}
 
+	// Set default background color for entire screen
+	screen.SetStyle(tcell.StyleDefault.
+		Foreground(theme.TextColor).
+		Background(theme.BackgroundColor))
+
+	// Open files at the lines asked for on the command line
+	for i, doc := range documents {
+		if targets[i] == nil {
+			continue
+		}
+		state := &doc.state
+		if doc == focus.document {
+			state = &focus.viewState
+		}
+		state.jumpTo(doc.targetPosition(*targets[i], 0), editor.layout().contentHeight)
+	}
+
+	// Stop on signals through the same path as the exit key
+	editor.watchSignals()
+
+	// Report problems with the config file where they will be seen, rather
+	// than on the terminal the screen has just taken over
+	for _, problem := range problems {
+		editor.draw()
+		editor.showMessage(problem)
+	}
+
+	// Offer to recover edits left in swap files by a session that ended
+	// without saving, unless nothing may be changed
+	if settings.SwapFiles && !options.ReadOnly {
+		editor.recoverSwapFiles()
+	}
+
 	return editor, nil
 }
 
 // Run starts the editor application
 func (e *Editor) Run() error {
-	// Set default background color for entire screen
-	e.screen.SetStyle(tcell.StyleDefault.
-		Foreground(e.theme.TextColor).
-		Background(e.theme.BackgroundColor))
-
 	// Draw the initial screen content
 	e.draw()
 
+	// Check open files for changes and keep swap files up to date
+	go e.runTicker()
+
 	// Main event loop
 	for {
-		ev := e.screen.PollEvent()
+		ev := e.pollEvent()
 
 		switch ev := ev.(type) {
 		case *tcell.EventResize:
 			e.screen.Sync()
 			e.draw()
 
+		case *signalEvent:
+			// Interrupted from outside; ask as if the exit key was pressed
+			if !e.shutdown(true) {
+				return nil
+			}
+			e.draw()
+
+		case *tickEvent:
+			if e.settings.WatchFiles {
+				e.checkFiles()
+			}
+			if e.settings.SwapFiles {
+				e.updateSwapFiles()
+			}
+			e.draw()
+
 		case *tcell.EventKey:
+			if e.gotoMode {
+				e.handleGotoInput(ev)
+				e.draw()
+				continue
+			}
 			if e.searchMode {
 				if !e.handleSearchInput(ev) {
 					e.draw()
@@ -182,173 +316,65 @@ func (e *Editor) Run() error {
 				}
 			}
 
-			if !e.handleKeyEvent(ev) {
-				return nil // Exit requested
-			}
-
-			// Only redraw for specific keys or periodically
-			shouldDraw := true
-
-			// Don't redraw for cursor navigation keys to improve speed
-			if ev.Key() == tcell.KeyDown || ev.Key() == tcell.KeyUp ||
-				ev.Key() == tcell.KeyLeft || ev.Key() == tcell.KeyRight {
-				// Set to false to skip redraw for cursor movement, making it much faster
-				shouldDraw = false
-			}
-
-			// Force redraw on Space key to let the user see current position
-			if ev.Key() == tcell.KeyRune && ev.Rune() == ' ' {
-				shouldDraw = true
+			// Count auto-repeats of a held Up/Down key to accelerate movement
+			if (ev.Key() == tcell.KeyUp || ev.Key() == tcell.KeyDown) &&
+				ev.Key() == e.lastKey && ev.When().Sub(e.lastKeyTime) < keyRepeatInterval {
+				e.keyCounter++
+			} else {
 				e.keyCounter = 0
 			}
+			e.lastKey = ev.Key()
+			e.lastKeyTime = ev.When()
 
-			// Periodically redraw every 20 navigation events to keep screen updated
-			if !shouldDraw {
-				// Get event count for this key
-				e.keyCounter++
-				if e.keyCounter >= 20 {
-					shouldDraw = true
-					e.keyCounter = 0
-				}
+			if !e.handleKeyEvent(ev) {
+				return nil // Exit requested
 			}
 
-			if shouldDraw {
-				e.draw()
-			}
+			e.draw()
 		}
 	}
 }
 
-// draw renders the editor content to the screen
-func (e *Editor) draw() {
-	e.screen.Clear()
+// screenLayout says which rows of the screen each part of the editor takes
+type screenLayout struct {
+	width, height int
+	contentHeight int // rows given to the panes, from the top of the screen
+	statusY       int // row of the status line
+	shortcutY     int // first of the two shortcut bar rows, -1 when hidden
+}
 
-	// Get screen dimensions
+// layout divides the screen between the panes, the status line and the
+// shortcut bar. The bar is left out on a screen too short to keep a few
+// rows for the content.
+func (e *Editor) layout() screenLayout {
 	width, height := e.screen.Size()
+	layout := screenLayout{width: width, height: height, shortcutY: -1}
 
-	// Set default style for background
-	defaultStyle := tcell.StyleDefault.
-		Foreground(e.theme.TextColor).
-		Background(e.theme.BackgroundColor)
-
-	// Fill entire screen with background color
-	for y := 0; y < height-1; y++ { // Leave the last line for status
-		for x := 0; x < width; x++ {
-			e.screen.SetContent(x, y, ' ', nil, defaultStyle)
-		}
+	bars := 1
+	if e.shortcutBar && height > 6 {
+		bars = 3
 	}
-
-	// Ensure cursor is visible
-	e.ensureVisibleCursor()
-
-	// Get the entire file content as a single string for syntax highlighting
-	content := strings.Join(e.content, "\n")
-
-	// Highlight the entire content
-	highlightedLines := e.highlighter.HighlightContent(content)
-
-	// Calculate the visible range of lines
-	visibleStart := e.scrollY
-	visibleEnd := e.scrollY + (height - 1) // Leave space for status line
-
-	// Allow displaying one line beyond content
-	maxVisibleEnd := len(e.content) + 1
-	if visibleEnd > maxVisibleEnd {
-		visibleEnd = maxVisibleEnd
+	layout.contentHeight = max(height-bars, 0)
+	layout.statusY = layout.contentHeight
+	if bars == 3 {
+		layout.shortcutY = layout.statusY + 1
 	}
+	return layout
+}
 
-	// Render visible content
-	for i := visibleStart; i < visibleEnd; i++ {
-		// Calculate screen position
-		y := i - e.scrollY
-
-		// Only render content if within actual content range
-		if i < len(e.content) {
-			line := e.content[i]
-
-			// Get the highlighted segments for this line
-			var colorSegments []syntax.ColorSegment
-			if i < len(highlightedLines) {
-				colorSegments = highlightedLines[i].Colors
-			}
-
-			// Draw the line with syntax highlighting
-			for x, r := range line {
-				if x >= width {
-					break
-				}
-
-				// Skip cursor position, we'll draw it separately
-				if i == e.cursorY && x == e.cursorX {
-					continue
-				}
-
-				// Default to using the default style
-				style := defaultStyle
-
-				// Check if we have a search result at this position
-				inSearchResult := false
-				if len(e.searchResults) > 0 {
-					for idx, result := range e.searchResults {
-						if i == result.Line && x >= result.Col && x < result.Col+result.Len {
-							// Highlight search matches
-							if idx == e.currentSearchIdx {
-								// Current match - make it stand out more
-								style = tcell.StyleDefault.
-									Foreground(e.theme.DialogBackground).
-									Background(e.theme.DialogSelectedBackground)
-							} else {
-								// Other matches
-								style = tcell.StyleDefault.
-									Foreground(e.theme.DialogButtonForeground).
-									Background(e.theme.DialogButtonBackground)
-							}
-							inSearchResult = true
-							break
-						}
-					}
-				}
-
-				// If not in a search result, use syntax highlighting
-				if !inSearchResult {
-					// Check if we have a highlighted segment that includes this position
-					for _, segment := range colorSegments {
-						if x >= segment.StartCol && x < segment.EndCol {
-							// Apply the highlight style but preserve background color
-							style = segment.Style.Background(e.theme.BackgroundColor)
-							break
-						}
-					}
-				}
+// draw renders the editor content to the screen
+func (e *Editor) draw() {
+	e.screen.Clear()
 
-				e.screen.SetContent(x, y, r, nil, style)
-			}
-		}
-		// The extra line beyond content is already drawn as empty space
-	}
+	// Work out where the panes and bars go
+	layout := e.layout()
+	width := layout.width
 
-	// Draw cursor (only if it's in the visible area)
-	if e.cursorY >= e.scrollY && e.cursorY < e.scrollY+height-1 && !e.searchMode {
-		// Get cursor screen position
-		cursorScreenY := e.cursorY - e.scrollY
-
-		// Get char under cursor
-		var cursorChar rune = ' ' // Default to space
-		if e.cursorY < len(e.content) {
-			line := e.content[e.cursorY]
-			if e.cursorX < len(line) {
-				cursorChar = rune(line[e.cursorX])
-			}
-		}
-
-		// Set cursor style with themed cursor color
-		cursorStyle := tcell.StyleDefault.
-			Foreground(e.theme.BackgroundColor).
-			Background(e.theme.CursorColor)
-
-		// Draw the cursor
-		e.screen.SetContent(e.cursorX, cursorScreenY, cursorChar, nil, cursorStyle)
-	}
+	// Draw the panes above the status line, then make the focused view
+	// current again
+	focus := e.view
+	e.drawLayout(e.root, focus, 0, 0, width, layout.contentHeight)
+	e.view = focus
 
 	// Draw status line
 	statusStyle := tcell.StyleDefault.
@@ -361,7 +387,7 @@ func (e *Editor) draw() {
 
 	// Fill status line with background color
 	for x := 0; x < width; x++ {
-		e.screen.SetContent(x, height-1, ' ', nil, statusStyle)
+		e.screen.SetContent(x, layout.statusY, ' ', nil, statusStyle)
 	}
 
 	// Get file type from highlighter
@@ -369,23 +395,46 @@ func (e *Editor) draw() {
 
 	// Show scroll position information
 	scrollInfo := ""
-	if len(e.content) > height-1 {
-		totalLines := len(e.content)
+	if e.scrollY > 0 || e.scrollRow > 0 || e.bottomLine < e.lineCount() {
+		totalLines := e.lineCount()
 		visibleStart := e.scrollY + 1
-		visibleEnd := min(e.scrollY+height-1, totalLines)
+		visibleEnd := e.bottomLine
 		scrollPercentage := 100 * visibleEnd / totalLines
 		scrollInfo = fmt.Sprintf(" %c %d-%d/%d %c %d%%",
 			e.theme.IconPosition, visibleStart, visibleEnd, totalLines,
 			e.theme.IconPercentage, scrollPercentage)
 	}
 
-	// Show keybindings with icons
-	keybindings := fmt.Sprintf("%c:Save %c:Exit %c:Find",
-		e.theme.IconSave, e.theme.IconExit, e.theme.IconFind)
+	// Show the keys of the main commands with icons, as currently bound,
+	// unless the shortcut bar already shows them
+	keybindings := ""
+	for _, hint := range []struct {
+		icon    rune
+		command string
+		label   string
+	}{
+		{e.theme.IconSave, "save", "Save"},
+		{e.theme.IconExit, "quit", "Exit"},
+		{e.theme.IconFind, "find", "Find"},
+	} {
+		if keys := e.keymap.keysFor(hint.command); len(keys) > 0 && layout.shortcutY < 0 {
+			keybindings += fmt.Sprintf(" %c %s %s", hint.icon, keyLabel(keys[0]), hint.label)
+		}
+	}
+	keybindings = strings.TrimPrefix(keybindings, " ")
+
+	// Show which buffer this is when several are open
+	bufferInfo := ""
+	if len(e.documents) > 1 {
+		bufferInfo = fmt.Sprintf(" [%d/%d]", e.documentIndex()+1, len(e.documents))
+	}
+	if e.readOnly {
+		bufferInfo += " [read-only]"
+	}
 
 	// Create the status text with icons
-	statusText := fmt.Sprintf(" %c %c %s [%s] [%d:%d]%s",
-		e.theme.IconModified, e.theme.IconFile, e.filePath, fileType, e.cursorY+1, e.cursorX+1, scrollInfo)
+	statusText := fmt.Sprintf(" %c %c %s%s [%s] [%s] [%d:%d]%s",
+		e.theme.IconModified, e.theme.IconFile, e.filePath, bufferInfo, fileType, e.format, e.cursorY+1, e.cursorColumn()+1, scrollInfo)
 
 	// Draw the status text
 	x := 0
@@ -397,319 +446,318 @@ func (e *Editor) draw() {
 				r == e.theme.IconPosition || r == e.theme.IconPercentage {
 				style = iconStyle
 			}
-			e.screen.SetContent(x, height-1, r, nil, style)
-			x++
+			x = e.drawText(x, layout.statusY, string(r), style)
 		}
 	}
 
 	// Draw keybindings on the right side
-	if len(keybindings) < width {
-		startX := width - len(keybindings) - 1
-		for i, r := range keybindings {
+	if keybindingsWidth := stringWidth(keybindings); keybindingsWidth < width {
+		x := width - keybindingsWidth - 1
+		for _, r := range keybindings {
 			// Use icon style for icons
 			style := statusStyle
 			if r == e.theme.IconSave || r == e.theme.IconExit ||
 				r == e.theme.IconFind {
 				style = iconStyle
 			}
-			e.screen.SetContent(startX+i, height-1, r, nil, style)
+			x = e.drawText(x, layout.statusY, string(r), style)
 		}
 	}
 
+	// Draw the shortcut bar below the status line
+	if layout.shortcutY >= 0 {
+		e.drawShortcutBar(layout)
+	}
+
 	// If in search mode, draw the search input
 	if e.searchMode {
 		e.drawSearchInput()
 	}
+	if e.gotoMode {
+		e.drawGotoInput()
+	}
 
 	// Show the result
 	e.screen.Show()
 }
 
-// handleKeyEvent processes keyboard input events
-func (e *Editor) handleKeyEvent(ev *tcell.EventKey) bool {
-	// Get screen dimensions
-	_, height := e.screen.Size()
-	contentHeight := height - 1 // Subtract status line
-
-	// Handle key events
-	switch ev.Key() {
-	case tcell.KeyCtrlC: // Legacy exit - immediately quit
-		close(e.quit)
-		e.screen.Fini()
-		return false
+// drawPane renders the current view into its pane. Only the focused view
+// shows the cursor.
+func (e *Editor) drawPane(focus *view) {
+	// Set default style for background
+	defaultStyle := tcell.StyleDefault.
+		Foreground(e.theme.TextColor).
+		Background(e.theme.BackgroundColor)
 
-	case tcell.KeyCtrlX: // Exit with prompt if modified
-		if e.modified {
-			return e.promptSaveBeforeExit()
-		}
-		close(e.quit)
-		e.screen.Fini()
-		return false
+	// Style for selected text
+	selectionStyle := tcell.StyleDefault.
+		Foreground(e.theme.SelectionForeground).
+		Background(e.theme.SelectionBackground)
 
-	case tcell.KeyCtrlS: // Save file
-		// If it's the default untitled file, we must prompt for a name
-		if e.filePath == "untitled.txt" && !fileExists(e.filePath) {
-			e.promptForFilename()
-		} else {
-			e.saveFile()
+	// Fill the pane with background color
+	for y := 0; y < e.height; y++ {
+		for x := 0; x < e.width; x++ {
+			e.screen.SetContent(e.x+x, e.y+y, ' ', nil, defaultStyle)
 		}
-		return true
+	}
 
-	case tcell.KeyCtrlF: // Find
-		e.enterSearchMode()
-		return true
+	// Ensure cursor is visible
+	e.ensureVisibleCursor()
 
-	case tcell.KeyCtrlV: // Paste
-		e.pasteFromClipboard()
-		return true
+	// Text starts after the line number gutter
+	gutter := e.gutterWidth()
+	left := e.x + gutter
+	textWidth := e.width - gutter
 
-	case tcell.KeyUp:
-		// Allow fast movement when holding Up key - move multiple lines at once
-		moveAmount := 1
+	// Search matches belong to the focused view's document
+	var matches []SearchResult
+	if e.document == focus.document {
+		matches = e.searchResults
+	}
 
-		// If holding key down for a while (as tracked by keyCounter), increase speed
-		if e.keyCounter > 5 {
-			moveAmount = 3
-		}
-		if e.keyCounter > 10 {
-			moveAmount = 5
-		}
-		if e.keyCounter > 15 {
-			moveAmount = 10
+	// Calculate the visible range of lines; every line takes at least one row
+	visibleStart := e.scrollY
+	visibleEnd := e.scrollY + e.height
+
+	// Highlight only the visible lines
+	highlightedLines := e.highlighter.HighlightLines(e.buf, visibleStart, visibleEnd)
+
+	// Render visible content one screen row at a time. With soft wrap a line
+	// takes several rows, and the first line may start part way through them.
+	// The line beyond content is included so the cursor can sit on it.
+	cursorScreenX, cursorScreenY := -1, -1
+	y := 0
+	i := visibleStart
+	for ; i <= e.lineCount() && y < e.height; i++ {
+		line := ""
+		if i < e.lineCount() {
+			line = e.line(i)
 		}
 
-		// Apply the movement
-		newY := e.cursorY - moveAmount
-		if newY < 0 {
-			newY = 0 // Don't go above first line
+		// Get the highlighted segments for this line
+		var colorSegments []syntax.ColorSegment
+		if i-visibleStart < len(highlightedLines) {
+			colorSegments = highlightedLines[i-visibleStart].Colors
 		}
 
-		e.cursorY = newY
+		// The cursor line of the focused pane is highlighted
+		background := e.theme.BackgroundColor
+		if e.view == focus && i == e.cursorY {
+			background = e.theme.CurrentLineBackground
+		}
 
-		// Make sure cursorX is not beyond end of line
-		if e.cursorX > len(e.content[e.cursorY]) {
-			e.cursorX = len(e.content[e.cursorY])
+		starts := e.lineRows(i)
+		firstRow := 0
+		if i == e.scrollY {
+			firstRow = min(e.scrollRow, len(starts)-1)
 		}
 
-		// Update scroll position to keep cursor in view
-		e.ensureVisibleCursor()
-		return true
+		for row := firstRow; row < len(starts) && y < e.height; row++ {
+			if background != e.theme.BackgroundColor {
+				for x := 0; x < e.width; x++ {
+					e.screen.SetContent(e.x+x, e.y+y, ' ', nil, defaultStyle.Background(background))
+				}
+			}
 
-	case tcell.KeyDown:
-		// Allow fast movement when holding Down key - move multiple lines at once
-		maxY := len(e.content)
-		moveAmount := 1
+			// Only the first row of a line carries its number
+			if gutter > 0 && row == 0 && i < e.lineCount() {
+				e.drawLineNumber(i, y, gutter, background)
+			}
 
-		// If holding key down for a while (as tracked by keyCounter), increase speed
-		if e.keyCounter > 5 {
-			moveAmount = 3
-		}
-		if e.keyCounter > 10 {
-			moveAmount = 5
-		}
-		if e.keyCounter > 15 {
-			moveAmount = 10
-		}
+			// The row covers the text from start to end. x is the screen
+			// column within the whole line and offset the column shown at
+			// the left edge of the pane: the row start when wrapping,
+			// otherwise the horizontal scroll.
+			start, end := starts[row], len(line)
+			if row+1 < len(starts) {
+				end = starts[row+1]
+			}
+			x, offset := 0, e.scrollX
+			if e.softWrap {
+				x = screenColumn(line, start, e.tabWidth)
+				offset = x
+			}
 
-		// Apply the movement
-		newY := e.cursorY + moveAmount
-		if newY > maxY {
-			newY = maxY // Don't go beyond the extra line
-		}
+			// The cursor belongs to the row its offset falls on
+			if i == e.cursorY && wrapRow(starts, e.cursorX) == row {
+				cursorScreenX = screenColumn(line, e.cursorX, e.tabWidth) - offset
+				cursorScreenY = y
+			}
 
-		e.cursorY = newY
+			// Draw the row with syntax highlighting, one grapheme cluster
+			// at a time; col is the byte offset into the line
+			col := start
+			for col < end && x < offset+textWidth {
+				next := nextGrapheme(line, col)
+				cluster := line[col:next]
 
-		// Adjust cursor X if needed
-		if e.cursorY < maxY && e.cursorX > len(e.content[e.cursorY]) {
-			e.cursorX = len(e.content[e.cursorY])
-		} else if e.cursorY == maxY {
-			// We're on the extra line beyond content
-			e.cursorX = 0
-		}
+				cellWidth := graphemeWidth(cluster, x, e.tabWidth)
+				screenX := x - offset
 
-		// Update scroll position to keep cursor in view
-		e.ensureVisibleCursor()
-		return true
+				// A character cut by either edge is left blank, a wide one
+				// would otherwise spill past the pane
+				visible := cluster == "\t" || (screenX >= 0 && screenX+cellWidth <= textWidth)
 
-	case tcell.KeyLeft:
-		if e.cursorX > 0 {
-			e.cursorX--
-		} else if e.cursorY > 0 {
-			// Move to end of previous line
-			e.cursorY--
-			e.cursorX = len(e.content[e.cursorY])
-		}
-		return true
+				// Skip cursor position, we'll draw it separately
+				if visible && (e.view != focus || i != e.cursorY || col != e.cursorX) {
+					style := e.styleAt(i, col, colorSegments, matches, background)
+					if cluster == "\t" {
+						// Expand the tab to blank cells up to the next tab stop
+						for tabX := max(screenX, 0); tabX < screenX+cellWidth && tabX < textWidth; tabX++ {
+							e.screen.SetContent(left+tabX, e.y+y, ' ', nil, style)
+						}
+					} else {
+						mainc, combc := splitGrapheme(cluster)
+						e.screen.SetContent(left+screenX, e.y+y, mainc, combc, style)
+					}
+				}
 
-	case tcell.KeyRight:
-		// If we're on a normal line and can move right
-		if e.cursorY < len(e.content) && e.cursorX < len(e.content[e.cursorY]) {
-			e.cursorX++
-		} else if e.cursorY < len(e.content) {
-			// At the end of a normal line, move to the next line
-			e.cursorY++
-			e.cursorX = 0
-		}
-		return true
+				x += cellWidth
+				col = next
+			}
 
-	case tcell.KeyPgUp:
-		// Move cursor up by a page
-		if e.cursorY > 0 {
-			e.cursorY -= contentHeight
-			if e.cursorY < 0 {
-				e.cursorY = 0
+			// Mark a selected line break with a highlighted cell
+			if breakX := x - offset; row == len(starts)-1 && i < e.lineCount() &&
+				e.inSelection(i, len(line)) && breakX >= 0 && breakX < textWidth &&
+				!(e.view == focus && i == e.cursorY && len(line) == e.cursorX) {
+				e.screen.SetContent(left+breakX, e.y+y, ' ', nil, selectionStyle)
 			}
-			// Make sure cursorX is valid for the new line
-			if e.cursorX > len(e.content[e.cursorY]) {
-				e.cursorX = len(e.content[e.cursorY])
+
+			// Mark text clipped on either side of the pane
+			if !e.softWrap {
+				markerStyle := tcell.StyleDefault.
+					Foreground(e.theme.LineNumberColor).
+					Background(background)
+				if e.scrollX > 0 && len(line) > 0 {
+					e.screen.SetContent(left, e.y+y, clippedLeft, nil, markerStyle)
+				}
+				if col < len(line) {
+					e.screen.SetContent(left+textWidth-1, e.y+y, clippedRight, nil, markerStyle)
+				}
 			}
+
+			y++
 		}
-		return true
+	}
 
-	case tcell.KeyPgDn:
-		// Move cursor down by a page with no speed limitations
-		if e.cursorY < len(e.content)-1 {
-			e.cursorY += contentHeight
-			if e.cursorY >= len(e.content) {
-				e.cursorY = len(e.content) - 1
-			}
-			// Make sure cursorX is valid for the new line
-			if e.cursorX > len(e.content[e.cursorY]) {
-				e.cursorX = len(e.content[e.cursorY])
+	// Remember how far down the pane reaches for the status line
+	e.bottomLine = min(i, e.lineCount())
+
+	// Draw cursor (only if it's in the visible area)
+	if e.view == focus && cursorScreenY >= 0 && !e.searchMode && !e.gotoMode {
+		// Get the grapheme cluster under the cursor
+		cursorChar, cursorComb := ' ', []rune(nil) // Default to space
+		if e.cursorY < e.lineCount() {
+			line := e.line(e.cursorY)
+			if e.cursorX < len(line) && line[e.cursorX] != '\t' {
+				cursorChar, cursorComb = splitGrapheme(line[e.cursorX:nextGrapheme(line, e.cursorX)])
 			}
 		}
-		return true
 
-	case tcell.KeyHome:
-		// Move to beginning of line
-		e.cursorX = 0
-		return true
+		// Set cursor style with themed cursor color
+		cursorStyle := tcell.StyleDefault.
+			Foreground(e.theme.BackgroundColor).
+			Background(e.theme.CursorColor)
 
-	case tcell.KeyEnd:
-		// Move to end of line
-		if e.cursorY < len(e.content) {
-			e.cursorX = len(e.content[e.cursorY])
+		// Draw the cursor
+		if cursorScreenX >= 0 && cursorScreenX < textWidth {
+			e.screen.SetContent(left+cursorScreenX, e.y+cursorScreenY, cursorChar, cursorComb, cursorStyle)
 		}
-		return true
+	}
+}
 
-	case tcell.KeyEnter:
-		// Handle enter at the extra line - append a new line
-		if e.cursorY == len(e.content) {
-			// Add a new empty line
-			e.content = append(e.content, "")
-			e.cursorY = len(e.content) - 1
-			e.cursorX = 0
-			e.modified = true
-			return true
-		}
+// gutterWidth returns the number of columns taken by line numbers, including
+// the space separating them from the text. Narrow panes have no gutter.
+func (e *Editor) gutterWidth() int {
+	if e.settings.LineNumbers == config.LineNumbersOff {
+		return 0
+	}
+	width := max(len(strconv.Itoa(e.lineCount())), 3) + 1
+	if width*2 > e.width {
+		return 0
+	}
+	return width
+}
 
-		// Normal case - split the current line at cursor position
-		currentLine := e.content[e.cursorY]
-		leftPart := currentLine[:e.cursorX]
-		rightPart := ""
-		if e.cursorX < len(currentLine) {
-			rightPart = currentLine[e.cursorX:]
+// drawLineNumber draws the number of line i right-aligned in the gutter on
+// row y of the pane. Relative numbering shows the distance from the cursor
+// line, which itself keeps its absolute number.
+func (e *Editor) drawLineNumber(i, y, gutter int, background tcell.Color) {
+	number := i + 1
+	if e.settings.LineNumbers == config.LineNumbersRelative && i != e.cursorY {
+		number = i - e.cursorY
+		if number < 0 {
+			number = -number
 		}
+	}
 
-		// Update current line to be everything before cursor
-		e.content[e.cursorY] = leftPart
-
-		// Insert new line with everything after cursor
-		newContent := make([]string, len(e.content)+1)
-		copy(newContent, e.content[:e.cursorY+1])
-		newContent[e.cursorY+1] = rightPart
-		copy(newContent[e.cursorY+2:], e.content[e.cursorY+1:])
-		e.content = newContent
+	style := tcell.StyleDefault.
+		Foreground(e.theme.LineNumberColor).
+		Background(background)
+	if i == e.cursorY {
+		style = style.Foreground(e.theme.CurrentLineNumberColor)
+	}
 
-		// Move cursor to beginning of new line
-		e.cursorY++
-		e.cursorX = 0
-		e.modified = true
-		return true
+	label := fmt.Sprintf("%*d ", gutter-1, number)
+	e.drawText(e.x, e.y+y, label, style)
+}
 
-	case tcell.KeyBackspace, tcell.KeyBackspace2:
-		if e.cursorX > 0 {
-			// Delete the character before the cursor
-			currentLine := e.content[e.cursorY]
-			e.content[e.cursorY] = currentLine[:e.cursorX-1] + currentLine[e.cursorX:]
-			e.cursorX--
-			e.modified = true
-		} else if e.cursorY > 0 {
-			// We're at the beginning of a line, merge with the previous line
-			previousLine := e.content[e.cursorY-1]
-			currentLine := e.content[e.cursorY]
-
-			// Set cursor to the end of the previous line
-			e.cursorX = len(previousLine)
-
-			// Merge the lines
-			e.content[e.cursorY-1] = previousLine + currentLine
-
-			// Remove the current line
-			newContent := make([]string, len(e.content)-1)
-			copy(newContent, e.content[:e.cursorY])
-			copy(newContent[e.cursorY:], e.content[e.cursorY+1:])
-			e.content = newContent
-
-			// Move cursor up to the previous line
-			e.cursorY--
-			e.modified = true
-		}
-		return true
+// styleAt returns the style for the character at byte offset col of line i
+func (e *Editor) styleAt(i, col int, colorSegments []syntax.ColorSegment, matches []SearchResult, background tcell.Color) tcell.Style {
+	// Selection takes priority over search and syntax colors
+	if e.inSelection(i, col) {
+		return tcell.StyleDefault.
+			Foreground(e.theme.SelectionForeground).
+			Background(e.theme.SelectionBackground)
+	}
 
-	case tcell.KeyDelete:
-		if e.cursorY < len(e.content) {
-			currentLine := e.content[e.cursorY]
-			if e.cursorX < len(currentLine) {
-				// Delete character at cursor
-				e.content[e.cursorY] = currentLine[:e.cursorX] + currentLine[e.cursorX+1:]
-				e.modified = true
-			} else if e.cursorY < len(e.content)-1 {
-				// At the end of the line, merge with next line
-				nextLine := e.content[e.cursorY+1]
-				e.content[e.cursorY] = currentLine + nextLine
-
-				// Remove the next line
-				newContent := make([]string, len(e.content)-1)
-				copy(newContent, e.content[:e.cursorY+1])
-				copy(newContent[e.cursorY+1:], e.content[e.cursorY+2:])
-				e.content = newContent
-				e.modified = true
+	// Check if we have a search result at this position
+	for idx, result := range matches {
+		if i == result.Line && col >= result.Col && col < result.Col+result.Len {
+			// Highlight search matches
+			if idx == e.currentSearchIdx {
+				// Current match - make it stand out more
+				return tcell.StyleDefault.
+					Foreground(e.theme.DialogBackground).
+					Background(e.theme.DialogSelectedBackground)
 			}
+			// Other matches
+			return tcell.StyleDefault.
+				Foreground(e.theme.DialogButtonForeground).
+				Background(e.theme.DialogButtonBackground)
 		}
-		return true
+	}
 
-	case tcell.KeyTab:
-		// Insert a tab (4 spaces for now)
-		currentLine := e.content[e.cursorY]
-		if e.cursorX > len(currentLine) {
-			e.content[e.cursorY] = currentLine + strings.Repeat(" ", e.cursorX-len(currentLine)) + "    "
-		} else {
-			e.content[e.cursorY] = currentLine[:e.cursorX] + "    " + currentLine[e.cursorX:]
+	// Check if we have a highlighted segment that includes this position
+	for _, segment := range colorSegments {
+		if col >= segment.StartCol && col < segment.EndCol {
+			// Apply the highlight style but preserve background color
+			return segment.Style.Background(background)
 		}
-		e.cursorX += 4
-		e.modified = true
-		return true
+	}
 
-	case tcell.KeyRune:
-		r := ev.Rune()
-		// Insert the character at cursor position
-		currentLine := e.content[e.cursorY]
-		newLine := ""
-		if e.cursorX > len(currentLine) {
-			// If cursor is beyond the end of the line, pad with spaces
-			newLine = currentLine + strings.Repeat(" ", e.cursorX-len(currentLine)) + string(r)
-		} else {
-			newLine = currentLine[:e.cursorX] + string(r) + currentLine[e.cursorX:]
-		}
+	// Default to using the default style
+	return tcell.StyleDefault.
+		Foreground(e.theme.TextColor).
+		Background(background)
+}
 
-		e.content[e.cursorY] = newLine
-		e.cursorX++
-		e.modified = true
-		return true
+// handleKeyEvent runs the command bound to the key pressed, or types it.
+// It returns false once the editor has shut down.
+func (e *Editor) handleKeyEvent(ev *tcell.EventKey) bool {
+	if cmd := e.keymap.lookup(ev); cmd != nil {
+		// Shift+movement extends the selection, plain movement clears it
+		if cmd.movement {
+			e.updateSelectionForMove(ev)
+		}
+		e.runCommand(cmd)
+		return !e.stopped()
 	}
 
-	// Pass other keys through
+	// Type characters, including Alt+key combinations with no binding
+	if ev.Key() == tcell.KeyRune && e.checkWritable() {
+		e.insertAtCursor(string(ev.Rune()), groupTyping)
+	}
 	return true
 }
 
@@ -721,18 +769,36 @@ func (e *Editor) saveFile() {
 		return
 	}
 
-	content := strings.Join(e.content, "\n")
+	// Don't silently clobber changes made by another program
+	if !e.confirmOverwrite() {
+		return
+	}
+
+	// Keep the version being replaced, unless the user would rather save
+	// without it
+	if err := backupFile(e.filePath, e.settings); err != nil {
+		choice := e.askChoice("Backup Failed", fmt.Sprintf("Cannot back up %s: %v", e.name(), err),
+			[]string{"Save Anyway", "Cancel"})
+		if choice != 0 {
+			return
+		}
+	}
 
-	err := os.WriteFile(e.filePath, []byte(content), 0644)
+	err := writeFile(e.filePath, e.buf, e.format)
 	if err != nil {
 		e.showMessage(fmt.Sprintf("Error saving file: %v", err))
 		return
 	}
 
-	e.modified = false
+	e.markSaved()
+	e.removeSwap()
+	if stamp, err := readStamp(e.filePath); err == nil {
+		e.stamp = stamp
+	}
 
 	// Update highlighter in case file type changed
-	e.highlighter = syntax.NewHighlighter(e.filePath)
+	e.resetHighlighter()
+	e.applyIndentStyle(e.settings)
 }
 
 // fileExists checks if a file exists and is not a directory
@@ -756,18 +822,6 @@ func (e *Editor) promptForFilename() {
 	dialogY := (height - dialogHeight) / 2
 
 	// Create styles
-	dialogStyle := tcell.StyleDefault.
-		Foreground(e.theme.DialogForeground).
-		Background(e.theme.DialogBackground)
-
-	borderStyle := tcell.StyleDefault.
-		Foreground(e.theme.DialogBorderColor).
-		Background(e.theme.DialogBackground)
-
-	titleStyle := tcell.StyleDefault.
-		Foreground(e.theme.DialogSelectedForeground).
-		Background(e.theme.DialogButtonBackground)
-
 	inputStyle := tcell.StyleDefault.
 		Foreground(e.theme.DialogForeground).
 		Background(e.theme.DialogBackground)
@@ -776,74 +830,16 @@ func (e *Editor) promptForFilename() {
 		Foreground(e.theme.DialogBackground).
 		Background(e.theme.DialogSelectedBackground)
 
-	// Shadow style
-	shadowStyle := tcell.StyleDefault.
-		Background(tcell.NewRGBColor(10, 10, 10)).
-		Foreground(tcell.NewRGBColor(10, 10, 10))
-
 	// Create an input field at the bottom of the screen
 	prompt := "Enter filename: "
 	input := e.filePath
 	if input == "untitled.txt" {
 		input = ""
 	}
-	title := " Save File "
-
-	// Box drawing characters
-	topLeft := '┌'
-	topRight := '┐'
-	bottomLeft := '└'
-	bottomRight := '┘'
-	horizontal := '─'
-	vertical := '│'
 
 	// Process input until Enter or Esc is pressed
 	for {
-		// Draw dialog shadow first (before the dialog)
-		for y := dialogY + 1; y <= dialogY+dialogHeight; y++ {
-			for x := dialogX + 2; x <= dialogX+dialogWidth+1; x++ {
-				if y == dialogY+dialogHeight || x == dialogX+dialogWidth+1 {
-					e.screen.SetContent(x, y, ' ', nil, shadowStyle)
-				}
-			}
-		}
-
-		// Draw dialog background
-		for y := dialogY; y < dialogY+dialogHeight; y++ {
-			for x := dialogX; x < dialogX+dialogWidth; x++ {
-				// Fill with background
-				e.screen.SetContent(x, y, ' ', nil, dialogStyle)
-			}
-		}
-
-		// Draw dialog border
-		// Top and bottom borders
-		for x := dialogX; x < dialogX+dialogWidth; x++ {
-			if x == dialogX {
-				e.screen.SetContent(x, dialogY, topLeft, nil, borderStyle)
-				e.screen.SetContent(x, dialogY+dialogHeight-1, bottomLeft, nil, borderStyle)
-			} else if x == dialogX+dialogWidth-1 {
-				e.screen.SetContent(x, dialogY, topRight, nil, borderStyle)
-				e.screen.SetContent(x, dialogY+dialogHeight-1, bottomRight, nil, borderStyle)
-			} else {
-				e.screen.SetContent(x, dialogY, horizontal, nil, borderStyle)
-				e.screen.SetContent(x, dialogY+dialogHeight-1, horizontal, nil, borderStyle)
-			}
-		}
-
-		// Left and right borders
-		for y := dialogY + 1; y < dialogY+dialogHeight-1; y++ {
-			e.screen.SetContent(dialogX, y, vertical, nil, borderStyle)
-			e.screen.SetContent(dialogX+dialogWidth-1, y, vertical, nil, borderStyle)
-		}
-
-		// Draw title
-		titleX := dialogX + (dialogWidth-len(title))/2
-		for i, c := range title {
-			if titleX+i >= dialogX+1 && titleX+i < dialogX+dialogWidth-1 {
-				e.screen.SetContent(titleX+i, dialogY, c, nil, titleStyle)
-			}
-		}
+		e.drawDialogFrame(dialogX, dialogY, dialogWidth, dialogHeight, "Save File")
 
 		// Draw prompt
 		promptX := dialogX + 3
@@ -853,33 +849,34 @@ func (e *Editor) promptForFilename() {
 
 		// Draw input
 		inputX := promptX + len(prompt)
-		for i, c := range input {
-			if inputX+i < dialogX+dialogWidth-3 {
-				e.screen.SetContent(inputX+i, dialogY+5, c, nil, inputStyle)
-			}
-		}
+		inputWidth := stringWidth(input)
+		e.drawText(inputX, dialogY+5, input, inputStyle)
 
 		// Draw input field border
 		inputFieldWidth := dialogWidth - 6
 		for x := promptX; x < promptX+inputFieldWidth; x++ {
-			if i := x - inputX; i >= 0 && i < len(input) {
+			if i := x - inputX; i >= 0 && i < inputWidth {
 				continue // Skip where there's text
 			}
 			e.screen.SetContent(x, dialogY+5, '_', nil, inputStyle)
 		}
 
 		// Show cursor
-		e.screen.SetContent(inputX+len(input), dialogY+5, ' ', nil, cursorStyle)
+		e.screen.SetContent(inputX+inputWidth, dialogY+5, ' ', nil, cursorStyle)
 
 		e.screen.Show()
 
 		// Wait for key event
-		ev := e.screen.PollEvent()
+		ev := e.pollEvent()
 		switch ev := ev.(type) {
 		case *tcell.EventKey:
 			switch ev.Key() {
 			case tcell.KeyEnter:
 				if input != "" {
+					// A different file has no known version on disk
+					if input != e.filePath {
+						e.stamp = fileStamp{}
+					}
 					e.filePath = input
 					e.saveFile()
 					return
@@ -888,11 +885,12 @@ func (e *Editor) promptForFilename() {
 				return
 			case tcell.KeyBackspace, tcell.KeyBackspace2:
 				if len(input) > 0 {
-					input = input[:len(input)-1]
+					_, size := utf8.DecodeLastRuneInString(input)
+					input = input[:len(input)-size]
 				}
 			case tcell.KeyRune:
 				// Only add the character if it would fit in the dialog
-				if inputX+len(input) < dialogX+dialogWidth-3 {
+				if inputX+stringWidth(input) < dialogX+dialogWidth-3 {
 					input += string(ev.Rune())
 				}
 			}
@@ -926,47 +924,77 @@ func (e *Editor) drawSearchInput() {
 		Foreground(e.theme.StatusIconColor).
 		Background(e.theme.DialogBackground)
 
-	prompt := fmt.Sprintf("%c Search: ", e.theme.IconFind)
-
 	// Draw search bar at the top of the screen
 	for x := 0; x < width; x++ {
 		e.screen.SetContent(x, 0, ' ', nil, inputBgStyle)
 	}
 
 	// Draw prompt with icon
-	for i, c := range prompt {
-		style := inputBgStyle
-		if i == 0 {
-			style = iconStyle
+	x := e.drawText(0, 0, string(e.theme.IconFind), iconStyle)
+	x = e.drawText(x, 0, e.replacePrompt(), inputBgStyle)
+
+	// Draw the search query or replacement being typed, with a cursor
+	if e.replaceStage != replaceConfirm {
+		input := e.searchQuery
+		if e.replaceStage == replaceWith {
+			input = e.replaceText
 		}
-		e.screen.SetContent(i, 0, c, nil, style)
+		x = e.drawText(x, 0, input, inputBgStyle)
+		e.screen.SetContent(x, 0, ' ', nil, cursorStyle)
 	}
 
-	// Draw search query
-	for i, c := range e.searchQuery {
-		e.screen.SetContent(len(prompt)+i, 0, c, nil, inputBgStyle)
+	// Show the search options on the right, highlighting those enabled
+	optionStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogButtonForeground).
+		Background(e.theme.DialogBackground)
+	activeStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogSelectedForeground).
+		Background(e.theme.DialogSelectedBackground)
+	options := []struct {
+		label   string
+		enabled bool
+	}{
+		{" Aa ", e.searchCaseSensitive},
+		{" \\b ", e.searchWholeWord},
+		{" .* ", e.searchRegex},
+	}
+	optionsX := width
+	for _, option := range options {
+		optionsX -= stringWidth(option.label) + 1
+	}
+	for _, option := range options {
+		style := optionStyle
+		if option.enabled {
+			style = activeStyle
+		}
+		optionsX = e.drawText(optionsX, 0, option.label, style) + 1
 	}
 
-	// Draw cursor
-	e.screen.SetContent(len(prompt)+len(e.searchQuery), 0, ' ', nil, cursorStyle)
+	// Show an invalid pattern instead of a result count
+	if e.searchErr != "" {
+		e.drawText(x+2, 0, "Invalid pattern: "+e.searchErr, inputBgStyle)
+		return
+	}
 
 	// Show search count if there are results
 	if len(e.searchResults) > 0 {
-		countText := fmt.Sprintf(" %c %d/%d", e.theme.IconPosition, e.currentSearchIdx+1, len(e.searchResults))
-		countX := len(prompt) + len(e.searchQuery) + 2
-
-		for i, c := range countText {
-			style := inputBgStyle
-			if i == 0 {
-				style = iconStyle
-			}
-			e.screen.SetContent(countX+i, 0, c, nil, style)
-		}
+		countX := e.drawText(x+2, 0, " ", inputBgStyle)
+		countX = e.drawText(countX, 0, string(e.theme.IconPosition), iconStyle)
+		e.drawText(countX, 0, fmt.Sprintf(" %d/%d", e.currentSearchIdx+1, len(e.searchResults)), inputBgStyle)
 	}
 }
 
 // handleSearchInput handles keyboard input during search mode
 func (e *Editor) handleSearchInput(ev *tcell.EventKey) bool {
+	if e.replaceStage != replaceOff {
+		return e.handleReplaceInput(ev)
+	}
+	return e.handleSearchKey(ev)
+}
+
+// handleSearchKey handles keys that edit the search query and move between
+// results
+func (e *Editor) handleSearchKey(ev *tcell.EventKey) bool {
 	switch ev.Key() {
 	case tcell.KeyEscape:
 		e.exitSearchMode()
@@ -982,12 +1010,18 @@ func (e *Editor) handleSearchInput(ev *tcell.EventKey) bool {
 
 	case tcell.KeyBackspace, tcell.KeyBackspace2:
 		if len(e.searchQuery) > 0 {
-			e.searchQuery = e.searchQuery[:len(e.searchQuery)-1]
+			_, size := utf8.DecodeLastRuneInString(e.searchQuery)
+			e.searchQuery = e.searchQuery[:len(e.searchQuery)-size]
 			e.performSearch()
 		}
 		return false
 
 	case tcell.KeyRune:
+		// Alt+C, Alt+W and Alt+R toggle the search options
+		if ev.Modifiers()&tcell.ModAlt != 0 {
+			e.toggleSearchOption(ev.Rune())
+			return false
+		}
 		e.searchQuery += string(ev.Rune())
 		e.performSearch()
 		return false
@@ -1001,35 +1035,51 @@ func (e *Editor) performSearch() {
 	if e.searchQuery == "" {
 		e.searchResults = []SearchResult{}
 		e.currentSearchIdx = -1
+		e.searchErr = ""
 		return
 	}
 
-	// Find all occurrences of the search query
-	query := strings.ToLower(e.searchQuery)
-	results := []SearchResult{}
+	// Build the pattern for the current search options, reporting an
+	// invalid regular expression in the search bar
+	pattern, err := e.compileSearchPattern()
+	if err != nil {
+		var syntaxErr *regexpsyntax.Error
+		if errors.As(err, &syntaxErr) {
+			e.searchErr = syntaxErr.Code.String()
+		} else {
+			e.searchErr = err.Error()
+		}
+		e.searchResults = []SearchResult{}
+		e.currentSearchIdx = -1
+		return
+	}
+	e.searchErr = ""
+	e.searchPattern = pattern
 
-	for lineIdx, line := range e.content {
-		lineLower := strings.ToLower(line)
-		startIdx := 0
+	// Find all occurrences of the search pattern
+	results := []SearchResult{}
 
-		for {
-			foundIdx := strings.Index(lineLower[startIdx:], query)
-			if foundIdx == -1 {
-				break
+	for lineIdx := 0; lineIdx < e.lineCount(); lineIdx++ {
+		for _, match := range pattern.FindAllStringSubmatchIndex(e.line(lineIdx), -1) {
+			// Empty matches can't be highlighted or replaced
+			if match[0] == match[1] {
+				continue
 			}
-
-			// Calculate the actual position in the line
-			actualIdx := startIdx + foundIdx
-
-			// Add this result
-			results = append(results, SearchResult{
+			result := SearchResult{
 				Line: lineIdx,
-				Col:  actualIdx,
-				Len:  len(query),
-			})
-
-			// Move start index for next search
-			startIdx = actualIdx + len(query)
+				Col:  match[0],
+				Len:  match[1] - match[0],
+			}
+			if e.searchRegex {
+				result.Groups = make([]int, len(match))
+				for i, offset := range match {
+					if offset >= 0 {
+						offset -= match[0]
+					}
+					result.Groups[i] = offset
+				}
+			}
+			results = append(results, result)
 		}
 	}
 
@@ -1044,6 +1094,39 @@ func (e *Editor) performSearch() {
 	}
 }
 
+// compileSearchPattern turns the query into a regular expression according
+// to the search options. A plain query is matched literally; matching it
+// through regexp also keeps case-insensitive offsets valid for the original
+// line where upper and lower case forms differ in length.
+func (e *Editor) compileSearchPattern() (*regexp.Regexp, error) {
+	expr := e.searchQuery
+	if !e.searchRegex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if e.searchWholeWord {
+		expr = `\b(?:` + expr + `)\b`
+	}
+	if !e.searchCaseSensitive {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// toggleSearchOption flips the search option bound to an Alt+key shortcut
+func (e *Editor) toggleSearchOption(r rune) {
+	switch unicode.ToLower(r) {
+	case 'c':
+		e.searchCaseSensitive = !e.searchCaseSensitive
+	case 'w':
+		e.searchWholeWord = !e.searchWholeWord
+	case 'r':
+		e.searchRegex = !e.searchRegex
+	default:
+		return
+	}
+	e.performSearch()
+}
+
 // navigateToSearchResult positions the cursor at a search result
 func (e *Editor) navigateToSearchResult(idx int) {
 	if idx < 0 || idx >= len(e.searchResults) {
@@ -1051,6 +1134,7 @@ func (e *Editor) navigateToSearchResult(idx int) {
 	}
 
 	result := e.searchResults[idx]
+	e.clearSelection()
 	e.cursorY = result.Line
 	e.cursorX = result.Col
 
@@ -1065,16 +1149,21 @@ func (e *Editor) exitSearchMode() {
 
 // ensureVisibleCursor adjusts scroll position to keep cursor in view
 func (e *Editor) ensureVisibleCursor() {
-	_, height := e.screen.Size()
-	contentHeight := height - 1 // Leave space for status line
+	contentHeight := max(e.height, 1)
 
 	// Ensure cursor position is valid
-	maxY := len(e.content)
+	maxY := e.lineCount()
 	if e.cursorY > maxY {
 		e.cursorY = maxY
 		e.cursorX = 0
 	}
 
+	if e.softWrap {
+		e.ensureVisibleWrapped(contentHeight)
+		return
+	}
+	e.scrollRow = 0
+
 	// If cursor is above the visible area, scroll up
 	if e.cursorY < e.scrollY {
 		e.scrollY = e.cursorY
@@ -1084,6 +1173,17 @@ func (e *Editor) ensureVisibleCursor() {
 	if e.cursorY >= e.scrollY+contentHeight {
 		e.scrollY = e.cursorY - contentHeight + 1
 	}
+
+	// Follow the cursor sideways, keeping the edge columns free for the
+	// clipped text markers
+	textWidth := max(e.width-e.gutterWidth(), 3)
+	column := e.cursorColumn()
+	if column < e.scrollX+1 {
+		e.scrollX = max(column-1, 0)
+	}
+	if column > e.scrollX+textWidth-2 {
+		e.scrollX = column - textWidth + 2
+	}
 }
 
 // min returns the minimum of two integers
@@ -1094,130 +1194,33 @@ func min(a, b int) int {
 	return b
 }
 
-// loadFile reads the content of a file into memory
-func loadFile(filePath string) ([]string, error) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, err
-	}
-
-	// Split content into lines
-	lines := strings.Split(string(content), "\n")
-
-	return lines, nil
-}
-
-// showMessage displays a message at the bottom of the screen
+// showMessage displays a message in a dialog until a key is pressed
 func (e *Editor) showMessage(message string) {
 	width, height := e.screen.Size()
 
 	// Dialog dimensions
-	dialogWidth := min(len(message)+8, width-4)
+	dialogWidth := min(stringWidth(message)+8, width-4)
 	dialogHeight := 7 // Increased for better spacing
 	dialogX := (width - dialogWidth) / 2
 	dialogY := (height - dialogHeight) / 2
 
-	// Create styles
-	dialogStyle := tcell.StyleDefault.
-		Foreground(e.theme.DialogForeground).
-		Background(e.theme.DialogBackground)
-
-	borderStyle := tcell.StyleDefault.
-		Foreground(e.theme.DialogBorderColor).
-		Background(e.theme.DialogBackground)
-
-	titleStyle := tcell.StyleDefault.
-		Foreground(e.theme.DialogSelectedForeground).
-		Background(e.theme.DialogButtonBackground)
-
 	textStyle := tcell.StyleDefault.
 		Foreground(e.theme.DialogForeground).
 		Background(e.theme.DialogBackground)
 
-	// Shadow style
-	shadowStyle := tcell.StyleDefault.
-		Background(tcell.NewRGBColor(10, 10, 10)).
-		Foreground(tcell.NewRGBColor(10, 10, 10))
-
-	// Box drawing characters
-	topLeft := '┌'
-	topRight := '┐'
-	bottomLeft := '└'
-	bottomRight := '┘'
-	horizontal := '─'
-	vertical := '│'
-
-	title := " Message "
-
-	// Draw dialog
 	for {
-		// Draw dialog shadow first
-		for y := dialogY + 1; y <= dialogY+dialogHeight; y++ {
-			for x := dialogX + 2; x <= dialogX+dialogWidth+1; x++ {
-				if y == dialogY+dialogHeight || x == dialogX+dialogWidth+1 {
-					e.screen.SetContent(x, y, ' ', nil, shadowStyle)
-				}
-			}
-		}
+		e.drawDialogFrame(dialogX, dialogY, dialogWidth, dialogHeight, "Message")
 
-		// Draw dialog background
-		for y := dialogY; y < dialogY+dialogHeight; y++ {
-			for x := dialogX; x < dialogX+dialogWidth; x++ {
-				// Fill with background
-				e.screen.SetContent(x, y, ' ', nil, dialogStyle)
-			}
-		}
-
-		// Draw dialog border
-		// Top and bottom borders
-		for x := dialogX; x < dialogX+dialogWidth; x++ {
-			if x == dialogX {
-				e.screen.SetContent(x, dialogY, topLeft, nil, borderStyle)
-				e.screen.SetContent(x, dialogY+dialogHeight-1, bottomLeft, nil, borderStyle)
-			} else if x == dialogX+dialogWidth-1 {
-				e.screen.SetContent(x, dialogY, topRight, nil, borderStyle)
-				e.screen.SetContent(x, dialogY+dialogHeight-1, bottomRight, nil, borderStyle)
-			} else {
-				e.screen.SetContent(x, dialogY, horizontal, nil, borderStyle)
-				e.screen.SetContent(x, dialogY+dialogHeight-1, horizontal, nil, borderStyle)
-			}
-		}
-
-		// Left and right borders
-		for y := dialogY + 1; y < dialogY+dialogHeight-1; y++ {
-			e.screen.SetContent(dialogX, y, vertical, nil, borderStyle)
-			e.screen.SetContent(dialogX+dialogWidth-1, y, vertical, nil, borderStyle)
-		}
-
-		// Draw title
-		titleX := dialogX + (dialogWidth-len(title))/2
-		for i, c := range title {
-			if titleX+i >= dialogX+1 && titleX+i < dialogX+dialogWidth-1 {
-				e.screen.SetContent(titleX+i, dialogY, c, nil, titleStyle)
-			}
-		}
-
-		// Write message
-		msgX := dialogX + (dialogWidth-len(message))/2
-		for i, r := range message {
-			if msgX+i >= dialogX+1 && msgX+i < dialogX+dialogWidth-1 {
-				e.screen.SetContent(msgX+i, dialogY+3, r, nil, textStyle)
-			}
-		}
-
-		// Draw a hint at the bottom
-		hint := "Press any key to continue"
-		hintX := dialogX + (dialogWidth-len(hint))/2
-		for i, r := range hint {
-			if hintX+i >= dialogX+1 && hintX+i < dialogX+dialogWidth-1 {
-				e.screen.SetContent(hintX+i, dialogY+dialogHeight-2, r, nil, textStyle)
-			}
-		}
+		// Draw the message and a hint at the bottom, measured in screen
+		// cells so wide characters keep them centred inside the border
+		left, right := dialogX+1, dialogX+dialogWidth-1
+		e.drawCentred(left, right, dialogY+3, message, textStyle)
+		e.drawCentred(left, right, dialogY+dialogHeight-2, "Press any key to continue", textStyle)
 
 		e.screen.Show()
 
 		// Wait for a key event to dismiss the message
-		ev := e.screen.PollEvent()
+		ev := e.pollEvent()
 		switch ev.(type) {
 		case *tcell.EventKey:
 			return
@@ -1225,317 +1228,191 @@ func (e *Editor) showMessage(message string) {
 	}
 }
 
-// promptSaveBeforeExit asks the user if they want to save before exiting
+// promptSaveBeforeExit asks the user if they want to save the current buffer
+// before exiting. It returns false if the exit was cancelled, including when
+// the save did not go through.
 func (e *Editor) promptSaveBeforeExit() bool {
-	width, height := e.screen.Size()
-
-	// Options
-	options := []string{"Save", "Don't Save", "Cancel"}
-	selected := 0
-
-	message := "Save changes before exiting?"
-
-	// Dialog dimensions
-	dialogWidth := min(50, width-4)
-	dialogHeight := 9 // Increased for better spacing
-	dialogX := (width - dialogWidth) / 2
-	dialogY := (height - dialogHeight) / 2
-
-	// Create styles
-	dialogStyle := tcell.StyleDefault.
-		Foreground(e.theme.DialogForeground).
-		Background(e.theme.DialogBackground)
-
-	borderStyle := tcell.StyleDefault.
-		Foreground(e.theme.DialogBorderColor).
-		Background(e.theme.DialogBackground)
-
-	titleStyle := tcell.StyleDefault.
-		Foreground(e.theme.DialogSelectedForeground).
-		Background(e.theme.DialogButtonBackground)
-
-	textStyle := tcell.StyleDefault.
-		Foreground(e.theme.DialogForeground).
-		Background(e.theme.DialogBackground)
-
-	buttonStyle := tcell.StyleDefault.
-		Foreground(e.theme.DialogButtonForeground).
-		Background(e.theme.DialogButtonBackground)
-
-	selectedStyle := tcell.StyleDefault.
-		Foreground(e.theme.DialogSelectedForeground).
-		Background(e.theme.DialogSelectedBackground)
-
-	// Shadow style
-	shadowStyle := tcell.StyleDefault.
-		Background(tcell.NewRGBColor(10, 10, 10)).
-		Foreground(tcell.NewRGBColor(10, 10, 10))
-
-	// Box drawing characters
-	topLeft := '┌'
-	topRight := '┐'
-	bottomLeft := '└'
-	bottomRight := '┘'
-	horizontal := '─'
-	vertical := '│'
-
-	title := " Confirm Exit "
-
-	for {
-		// Draw dialog shadow first
-		for y := dialogY + 1; y <= dialogY+dialogHeight; y++ {
-			for x := dialogX + 2; x <= dialogX+dialogWidth+1; x++ {
-				if y == dialogY+dialogHeight || x == dialogX+dialogWidth+1 {
-					e.screen.SetContent(x, y, ' ', nil, shadowStyle)
-				}
-			}
-		}
-
-		// Draw dialog background
-		for y := dialogY; y < dialogY+dialogHeight; y++ {
-			for x := dialogX; x < dialogX+dialogWidth; x++ {
-				// Fill with background
-				e.screen.SetContent(x, y, ' ', nil, dialogStyle)
-			}
-		}
-
-		// Draw dialog border
-		// Top and bottom borders
-		for x := dialogX; x < dialogX+dialogWidth; x++ {
-			if x == dialogX {
-				e.screen.SetContent(x, dialogY, topLeft, nil, borderStyle)
-				e.screen.SetContent(x, dialogY+dialogHeight-1, bottomLeft, nil, borderStyle)
-			} else if x == dialogX+dialogWidth-1 {
-				e.screen.SetContent(x, dialogY, topRight, nil, borderStyle)
-				e.screen.SetContent(x, dialogY+dialogHeight-1, bottomRight, nil, borderStyle)
-			} else {
-				e.screen.SetContent(x, dialogY, horizontal, nil, borderStyle)
-				e.screen.SetContent(x, dialogY+dialogHeight-1, horizontal, nil, borderStyle)
-			}
-		}
-
-		// Left and right borders
-		for y := dialogY + 1; y < dialogY+dialogHeight-1; y++ {
-			e.screen.SetContent(dialogX, y, vertical, nil, borderStyle)
-			e.screen.SetContent(dialogX+dialogWidth-1, y, vertical, nil, borderStyle)
-		}
-
-		// Draw title
-		titleX := dialogX + (dialogWidth-len(title))/2
-		for i, c := range title {
-			if titleX+i >= dialogX+1 && titleX+i < dialogX+dialogWidth-1 {
-				e.screen.SetContent(titleX+i, dialogY, c, nil, titleStyle)
-			}
-		}
-
-		// Draw message
-		for i, r := range message {
-			x := dialogX + (dialogWidth-len(message))/2 + i
-			y := dialogY + 3
-			if x >= dialogX+1 && x < dialogX+dialogWidth-1 {
-				e.screen.SetContent(x, y, r, nil, textStyle)
-			}
-		}
-
-		// Draw buttons
-		buttonY := dialogY + 6
-
-		// Calculate total width of all buttons with spacing
-		totalButtonWidth := 0
-		for _, opt := range options {
-			totalButtonWidth += len(opt) + 4 // Add padding around button text
+	message := fmt.Sprintf("Save changes to %s before exiting?", e.name())
+	switch e.askChoice("Confirm Exit", message, []string{"Save", "Don't Save", "Cancel"}) {
+	case 0:
+		if e.filePath == "untitled.txt" && !fileExists(e.filePath) {
+			e.promptForFilename()
+		} else {
+			e.saveFile()
 		}
-		totalButtonWidth += (len(options) - 1) * 3 // More spacing between buttons
-
-		// Start position for first button
-		buttonX := dialogX + (dialogWidth-totalButtonWidth)/2
-
-		for i, opt := range options {
-			// Draw button with rounded corners
-			buttonWidth := len(opt) + 4
-
-			// Button style based on selection
-			style := buttonStyle
-			if i == selected {
-				style = selectedStyle
-			}
-
-			// Button border and background
-			// Top border with rounded corners
-			e.screen.SetContent(buttonX, buttonY, '╭', nil, style)
-			e.screen.SetContent(buttonX+buttonWidth-1, buttonY, '╮', nil, style)
-
-			// Fill top row
-			for x := buttonX + 1; x < buttonX+buttonWidth-1; x++ {
-				e.screen.SetContent(x, buttonY, '─', nil, style)
-			}
-
-			// Middle row with text
-			e.screen.SetContent(buttonX, buttonY+1, '│', nil, style)
-			e.screen.SetContent(buttonX+buttonWidth-1, buttonY+1, '│', nil, style)
-
-			// Fill middle row
-			for x := buttonX + 1; x < buttonX+buttonWidth-1; x++ {
-				e.screen.SetContent(x, buttonY+1, ' ', nil, style)
-			}
-
-			// Bottom border with rounded corners
-			e.screen.SetContent(buttonX, buttonY+2, '╰', nil, style)
-			e.screen.SetContent(buttonX+buttonWidth-1, buttonY+2, '╯', nil, style)
-
-			// Fill bottom row
-			for x := buttonX + 1; x < buttonX+buttonWidth-1; x++ {
-				e.screen.SetContent(x, buttonY+2, '─', nil, style)
-			}
+		return !e.modified
+	case 1:
+		return true
+	}
+	return false
+}
 
-			// Button text
-			for j, r := range opt {
-				x := buttonX + 2 + j // Position text with padding
-				y := buttonY + 1     // Center text vertically
+// pasteFromClipboard inserts the clipboard contents at the cursor
+func (e *Editor) pasteFromClipboard() {
+	// Normalise line endings so pasted Windows text doesn't keep its \r
+	content := strings.ReplaceAll(e.clipboard.Paste(), "\r\n", "\n")
 
-				if x >= dialogX+1 && x < dialogX+dialogWidth-1 {
-					e.screen.SetContent(x, y, r, nil, style)
-				}
-			}
+	// If there's no content, do nothing
+	if len(content) == 0 {
+		return
+	}
 
-			// Move to next button position
-			buttonX += buttonWidth + 3
-		}
+	e.history.seal()
+	e.insertAtCursor(content, groupNone)
+}
 
-		e.screen.Show()
+// copyToClipboard copies the selection to the clipboard
+func (e *Editor) copyToClipboard() {
+	if !e.hasSelection() {
+		return
+	}
 
-		// Handle input
-		ev := e.screen.PollEvent()
-		switch ev := ev.(type) {
-		case *tcell.EventKey:
-			switch ev.Key() {
-			case tcell.KeyLeft:
-				selected = (selected + len(options) - 1) % len(options)
-			case tcell.KeyRight:
-				selected = (selected + 1) % len(options)
-			case tcell.KeyEnter:
-				switch selected {
-				case 0: // Save
-					if e.filePath == "untitled.txt" && !fileExists(e.filePath) {
-						e.promptForFilename()
-					} else {
-						e.saveFile()
-					}
-					close(e.quit)
-					e.screen.Fini()
-					return false
-				case 1: // Don't Save
-					close(e.quit)
-					e.screen.Fini()
-					return false
-				case 2: // Cancel
-					return true
-				}
-			case tcell.KeyEscape:
-				return true
-			}
-		}
+	start, end := e.selectionRange()
+	if err := e.clipboard.Copy(e.textBetween(start, end)); err != nil {
+		e.showMessage(fmt.Sprintf("Error copying to clipboard: %v", err))
 	}
 }
 
-// pasteFromClipboard implements paste functionality
-func (e *Editor) pasteFromClipboard() {
-	// Get clipboard content from the terminal
-	// This is a simplified implementation that assumes the system has xclip or pbpaste
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "darwin":
-		cmd = exec.Command("pbpaste")
-	case "linux":
-		cmd = exec.Command("xclip", "-selection", "clipboard", "-o")
-	default:
-		// Unsupported platform
+// cutToClipboard moves the selection, or the current line when nothing is
+// selected, to the clipboard
+func (e *Editor) cutToClipboard() {
+	var start, end position
+	if e.hasSelection() {
+		start, end = e.selectionRange()
+	} else if e.cursorY < e.lineCount() {
+		// Cut the whole line including its line break, like nano
+		start = position{e.cursorY, 0}
+		end = position{e.cursorY + 1, 0}
+		if e.cursorY == e.lineCount()-1 {
+			end = position{e.cursorY, e.lineLen(e.cursorY)}
+		}
+	}
+	if start == end {
 		return
 	}
 
-	out, err := cmd.Output()
-	if err != nil {
-		return // Failed to get clipboard content
+	if err := e.clipboard.Copy(e.textBetween(start, end)); err != nil {
+		e.showMessage(fmt.Sprintf("Error copying to clipboard: %v", err))
+		return
 	}
 
-	// Get the content as string and split by lines
-	content := string(out)
-	lines := strings.Split(content, "\n")
+	e.clearSelection()
+	e.history.seal()
+	e.deleteText(start, end, groupNone)
+	e.setCursor(start)
+}
 
-	// If there's no content, do nothing
-	if len(content) == 0 {
+// textBetween returns the content between start and end
+func (e *Editor) textBetween(start, end position) string {
+	return e.buf.Slice(e.offset(start), e.offset(end))
+}
+
+// moveCursorToLine moves the cursor to line y, keeping its screen column
+func (e *Editor) moveCursorToLine(y int) {
+	x := e.cursorColumn()
+	e.cursorY = y
+	if y >= e.lineCount() {
+		// We're on the extra line beyond content
+		e.cursorX = 0
 		return
 	}
+	e.cursorX = byteColumn(e.line(y), x, e.tabWidth)
+}
 
-	// Handle multi-line paste more efficiently
-	if len(lines) > 1 {
-		e.insertMultiLineText(lines)
-	} else {
-		// Single line paste
-		e.insertTextAtCursor(content)
+// cursorColumn returns the screen column of the cursor within its line
+func (e *Editor) cursorColumn() int {
+	if e.cursorY >= e.lineCount() {
+		return 0
 	}
-
-	e.modified = true
+	return screenColumn(e.line(e.cursorY), e.cursorX, e.tabWidth)
 }
 
-// insertMultiLineText inserts multiple lines of text efficiently
-func (e *Editor) insertMultiLineText(lines []string) {
-	// Handle the first line - append to current line at cursor position
-	currentLine := e.content[e.cursorY]
-	leftPart := currentLine[:e.cursorX]
-	rightPart := ""
-	if e.cursorX < len(currentLine) {
-		rightPart = currentLine[e.cursorX:]
-	}
+// cursorPos returns the cursor location as a position
+func (e *Editor) cursorPos() position {
+	return position{e.cursorY, e.cursorX}
+}
 
-	// Update first line
-	newFirstLine := leftPart + lines[0]
+// setCursor moves the cursor to pos
+func (e *Editor) setCursor(pos position) {
+	e.cursorY = pos.line
+	e.cursorX = pos.col
+}
 
-	// Create a new slice to hold all content
-	newContent := make([]string, len(e.content)+len(lines)-1)
+// insertText inserts text at pos, records it for undo and returns the
+// position just past the inserted text
+func (e *Editor) insertText(pos position, text string, group undoGroup) position {
+	if text == "" {
+		return pos
+	}
 
-	// Copy content before the cursor line
-	copy(newContent, e.content[:e.cursorY])
+	// Inserting on the extra line beyond content starts a new line
+	if pos.line >= e.lineCount() {
+		text = "\n" + text
+	}
+	pos = e.clampPosition(pos)
 
-	// Add the modified first line
-	newContent[e.cursorY] = newFirstLine
+	before := e.cursorPos()
+	end := e.rawInsert(pos, text)
+	e.history.record(edit{kind: editInsert, pos: pos, text: text}, group, before, end)
+	e.updateModified()
+	return end
+}
 
-	// Add all middle lines
-	for i := 1; i < len(lines)-1; i++ {
-		newContent[e.cursorY+i] = lines[i]
+// deleteText removes the text between start and end, records it for undo
+// and returns the removed text
+func (e *Editor) deleteText(start, end position, group undoGroup) string {
+	start = e.clampPosition(start)
+	end = e.clampPosition(end)
+	if start == end {
+		return ""
 	}
 
-	// Handle the last line + right part of split line
-	if len(lines) > 1 {
-		lastIdx := len(lines) - 1
-		newContent[e.cursorY+lastIdx] = lines[lastIdx] + rightPart
+	before := e.cursorPos()
+	removed := e.rawDelete(start, end)
+	e.history.record(edit{kind: editDelete, pos: start, text: removed}, group, before, start)
+	e.updateModified()
+	return removed
+}
 
-		// Move cursor to the end of the last inserted line
-		e.cursorY += lastIdx
-		e.cursorX = len(lines[lastIdx])
-	} else {
-		// Only one line was pasted, cursor should be after the inserted text
-		e.cursorX += len(lines[0])
-	}
+// rawInsert adds text to the buffer at pos without recording history and
+// returns the position just past the inserted text
+func (e *Editor) rawInsert(pos position, text string) position {
+	e.changes++
+	e.highlighter.Invalidate(pos.line)
+	e.buf.Insert(e.offset(pos), text)
+	end := textEnd(pos, text)
+	e.shiftViewsForInsert(pos, end)
+	return end
+}
 
-	// Copy content after the cursor line
-	copy(newContent[e.cursorY+1:], e.content[e.cursorY+1:])
+// rawDelete removes the text between start and end without recording
+// history and returns the removed text
+func (e *Editor) rawDelete(start, end position) string {
+	e.changes++
+	e.highlighter.Invalidate(start.line)
+	startOffset, endOffset := e.offset(start), e.offset(end)
+	removed := e.buf.Slice(startOffset, endOffset)
+	e.buf.Delete(startOffset, endOffset)
+	e.shiftViewsForDelete(start, end)
+	return removed
+}
 
-	// Update content
-	e.content = newContent
+// offset converts pos to a byte offset into the buffer
+func (e *Editor) offset(pos position) int {
+	return e.buf.Offset(pos.line, pos.col)
 }
 
-// insertTextAtCursor inserts a single line of text at the cursor position
-func (e *Editor) insertTextAtCursor(text string) {
-	// Insert text at cursor position
-	currentLine := e.content[e.cursorY]
-	if e.cursorX > len(currentLine) {
-		// Pad with spaces if cursor is beyond the end of the line
-		e.content[e.cursorY] = currentLine + strings.Repeat(" ", e.cursorX-len(currentLine)) + text
-	} else {
-		e.content[e.cursorY] = currentLine[:e.cursorX] + text + currentLine[e.cursorX:]
-	}
+// lineCount returns the number of lines in the buffer
+func (e *Editor) lineCount() int {
+	return e.buf.LineCount()
+}
+
+// line returns the text of a line without its line break
+func (e *Editor) line(i int) string {
+	return e.buf.Line(i)
+}
 
-	// Move cursor after inserted text
-	e.cursorX += len(text)
+// lineLen returns the length of a line in bytes
+func (e *Editor) lineLen(i int) int {
+	return e.buf.LineLen(i)
 }
@@ -0,0 +1,206 @@
+package editor
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// fileStamp identifies the version of a file on disk. The modification time
+// and size are checked first; the hash tells a rewrite with the same content
+// apart from a real change.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+// stampOf returns the stamp for content read from a file described by info
+func stampOf(info os.FileInfo, content []byte) fileStamp {
+	return fileStamp{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		hash:    sha256.Sum256(content),
+	}
+}
+
+// matches reports whether info has the modification time and size of the
+// version the stamp was taken from, which needs no read of the file
+func (s fileStamp) matches(info os.FileInfo) bool {
+	return info.ModTime().Equal(s.modTime) && info.Size() == s.size
+}
+
+// readStamp reads the file at filePath and returns its stamp
+func readStamp(filePath string) (fileStamp, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return stampOf(info, content), nil
+}
+
+// tickInterval is how often open files are checked for changes made by
+// other programs and modified buffers are written to their swap files
+const tickInterval = 2 * time.Second
+
+// tickEvent is posted to the event loop when it is time for the periodic
+// checks
+type tickEvent struct {
+	tcell.EventTime
+}
+
+// runTicker posts a tickEvent at regular intervals until the editor quits
+func (e *Editor) runTicker() {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.quit:
+			return
+		case <-ticker.C:
+			ev := &tickEvent{}
+			ev.SetEventNow()
+			e.screen.PostEvent(ev)
+		}
+	}
+}
+
+// changedOnDisk reports whether the document's file differs from the version
+// it was loaded from or last saved as. For a document never read from disk,
+// any existing file counts as a change.
+func (d *document) changedOnDisk() bool {
+	info, err := os.Stat(d.filePath)
+	if err != nil {
+		// A file deleted since loading is a change too
+		return errors.Is(err, os.ErrNotExist) && d.stamp != (fileStamp{})
+	}
+	if d.stamp == (fileStamp{}) {
+		return true
+	}
+	if d.stamp.matches(info) {
+		return false
+	}
+
+	// A file of another size has changed without reading it to be sure
+	if info.Size() != d.stamp.size {
+		return true
+	}
+
+	// Compare the content; a file touched but not changed gets its new time
+	// remembered so the hash isn't needed next time
+	content, err := os.ReadFile(d.filePath)
+	if err != nil {
+		return false
+	}
+	if stamp := stampOf(info, content); stamp.hash == d.stamp.hash {
+		d.stamp = stamp
+		return false
+	}
+	return true
+}
+
+// checkFiles offers to reload unmodified documents whose files were changed
+// by another program. Each change is only asked about once; modified
+// documents are left alone and warned about when saved. This runs on every
+// tick, so a file is read at most once, and only when its time and size
+// don't tell whether it changed.
+func (e *Editor) checkFiles() {
+	// Switching to or reloading a buffer would pull its matches out from
+	// under an open prompt, so wait until it is closed
+	if e.promptOpen() {
+		return
+	}
+
+	for idx, doc := range e.documents {
+		if doc.modified || doc.stamp == (fileStamp{}) {
+			continue
+		}
+
+		// A version the user chose to keep is not looked at again
+		info, err := os.Stat(doc.filePath)
+		if err != nil || doc.ignoredStamp.matches(info) || !doc.changedOnDisk() {
+			continue
+		}
+
+		e.switchDocument(idx)
+		e.draw()
+		choice := e.askChoice("File Changed",
+			fmt.Sprintf("%s was changed by another program. Reload it?", doc.name()),
+			[]string{"Reload", "Keep"})
+		if choice == 0 {
+			e.reloadDocument(doc)
+		} else {
+			doc.ignoredStamp = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+}
+
+// promptOpen reports whether the search, replace or go to line prompt is
+// taking the keyboard
+func (e *Editor) promptOpen() bool {
+	return e.searchMode || e.gotoMode || e.replaceStage != replaceOff
+}
+
+// confirmOverwrite warns before saving over a file that was changed on disk
+// and reports whether the save should go ahead. The user may instead reload
+// the file, dropping the changes in the buffer.
+func (e *Editor) confirmOverwrite() bool {
+	if !e.changedOnDisk() {
+		return true
+	}
+
+	message := fmt.Sprintf("%s has changed on disk since it was opened", e.name())
+	if _, err := os.Stat(e.filePath); errors.Is(err, os.ErrNotExist) {
+		message = fmt.Sprintf("%s was removed from disk since it was opened", e.name())
+	} else if e.stamp == (fileStamp{}) {
+		message = fmt.Sprintf("%s already exists on disk", e.name())
+	}
+
+	switch e.askChoice("File Changed", message, []string{"Overwrite", "Reload", "Cancel"}) {
+	case 0:
+		return true
+	case 1:
+		e.reloadDocument(e.document)
+	}
+	return false
+}
+
+// reloadDocument replaces the content of doc with its file on disk. The undo
+// history starts over, and views of the document keep their cursor where
+// the new content allows.
+func (e *Editor) reloadDocument(doc *document) {
+	content, format, stamp, err := loadFile(doc.filePath)
+	if err != nil {
+		e.showMessage(fmt.Sprintf("Error reloading file: %v", err))
+		return
+	}
+
+	doc.buf = content
+	doc.format, doc.savedFormat = format, format
+	doc.stamp = stamp
+	doc.resetHighlighter()
+	doc.history = newHistory()
+	doc.savedState = 0
+	doc.modified = false
+	doc.state = viewState{}
+
+	for _, v := range e.root.views() {
+		if v.document == doc {
+			v.setPos(doc.clampPosition(position{v.cursorY, v.cursorX}))
+			v.selecting = false
+		}
+	}
+	if doc == e.document {
+		e.searchResults = []SearchResult{}
+		e.currentSearchIdx = -1
+	}
+}
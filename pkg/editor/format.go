@@ -0,0 +1,174 @@
+package editor
+
+import (
+	"io"
+	"strings"
+)
+
+// lineEnding is the line break style of a file. The buffer always holds
+// text with "\n" line breaks; the file's own style is restored on save.
+type lineEnding int
+
+const (
+	endingLF lineEnding = iota
+	endingCRLF
+	endingCR
+)
+
+// utf8BOM is the byte order mark some editors put at the start of UTF-8 files
+const utf8BOM = "\xef\xbb\xbf"
+
+// String returns the name shown in the status bar
+func (l lineEnding) String() string {
+	switch l {
+	case endingCRLF:
+		return "CRLF"
+	case endingCR:
+		return "CR"
+	}
+	return "LF"
+}
+
+// sequence returns the characters that end a line in this style
+func (l lineEnding) sequence() string {
+	switch l {
+	case endingCRLF:
+		return "\r\n"
+	case endingCR:
+		return "\r"
+	}
+	return "\n"
+}
+
+// fileFormat describes how a document's text is stored on disk
+type fileFormat struct {
+	ending lineEnding
+	bom    bool
+
+	// The file mixes line ending styles. Its text is kept as it is, with
+	// the carriage returns in the buffer, and written back the same way.
+	mixed bool
+}
+
+// String returns the format as shown in the status bar
+func (f fileFormat) String() string {
+	name := f.ending.String()
+	if f.mixed {
+		name = "Mixed"
+	}
+	if f.bom {
+		return name + " BOM"
+	}
+	return name
+}
+
+// decodeText strips a byte order mark and converts line breaks to "\n",
+// returning the text together with the format it was stored in. The style
+// of the first line break is taken as the file's, and only breaks in that
+// style are converted, so a carriage return that doesn't end a line stays
+// in the text. Files mixing styles are kept verbatim and marked as mixed.
+// Either way saving writes back the same bytes.
+func decodeText(text string) (string, fileFormat) {
+	var format fileFormat
+
+	if strings.HasPrefix(text, utf8BOM) {
+		format.bom = true
+		text = text[len(utf8BOM):]
+	}
+
+	crlf := strings.Count(text, "\r\n")
+	lf := strings.Count(text, "\n") - crlf
+
+	i := strings.IndexAny(text, "\r\n")
+	switch {
+	case i < 0:
+		// A single line has no line ending to go by
+	case text[i] == '\n':
+		// Carriage returns are ordinary characters in an LF file
+		format.mixed = crlf > 0
+	case strings.HasPrefix(text[i:], "\r\n"):
+		if lf > 0 {
+			format.mixed = true
+		} else {
+			format.ending = endingCRLF
+			text = strings.ReplaceAll(text, "\r\n", "\n")
+		}
+	default:
+		if lf > 0 || crlf > 0 {
+			format.mixed = true
+		} else {
+			format.ending = endingCR
+			text = strings.ReplaceAll(text, "\r", "\n")
+		}
+	}
+	return text, format
+}
+
+// formatWriter writes text with "\n" line breaks in a file format
+type formatWriter struct {
+	w      io.Writer
+	format fileFormat
+	begun  bool
+}
+
+// Write converts the line breaks in p and writes it, preceded by the byte
+// order mark on the first call
+func (fw *formatWriter) Write(p []byte) (int, error) {
+	if !fw.begun {
+		fw.begun = true
+		if fw.format.bom {
+			if _, err := io.WriteString(fw.w, utf8BOM); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if fw.format.ending == endingLF {
+		return fw.w.Write(p)
+	}
+	converted := strings.ReplaceAll(string(p), "\n", fw.format.ending.sequence())
+	if _, err := io.WriteString(fw.w, converted); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// cycleLineEnding switches the current document to the next line ending
+// style; the change is written out on the next save. A file with mixed
+// line endings is first converted to LF, turning every CRLF and CR into a
+// line break as one undoable change.
+func (e *Editor) cycleLineEnding() {
+	if e.format.mixed {
+		e.normaliseLineBreaks()
+		e.format.mixed = false
+		e.format.ending = endingLF
+	} else {
+		e.format.ending = (e.format.ending + 1) % 3
+	}
+	e.updateModified()
+}
+
+// normaliseLineBreaks replaces the CRLF and CR line breaks left in the
+// buffer of a mixed file with "\n"
+func (e *Editor) normaliseLineBreaks() {
+	text := e.buf.String()
+	normalised := strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	if normalised == text {
+		return
+	}
+
+	cursor := e.cursorPos()
+	lastLine := e.lineCount() - 1
+	e.history.begin(cursor)
+	e.deleteText(position{0, 0}, position{lastLine, e.lineLen(lastLine)}, groupNone)
+	e.insertText(position{0, 0}, normalised, groupNone)
+	e.setCursor(e.clampPosition(cursor))
+	e.history.end(e.cursorPos())
+	e.history.seal()
+}
+
+// toggleBOM adds or removes the byte order mark on the next save
+func (e *Editor) toggleBOM() {
+	e.format.bom = !e.format.bom
+	e.updateModified()
+}
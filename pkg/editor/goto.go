@@ -0,0 +1,257 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// lineTarget is a place to go to, as typed in the go to line prompt or given
+// on the command line: "120", "120:15", "+50", "-10" or "%50"
+type lineTarget struct {
+	line     int  // line number from 1, lines to move, or percent of the way down
+	column   int  // column from 1 counted in characters, 0 if not given
+	relative bool // line is a number of lines to move from the cursor
+	percent  bool // line is a percentage of the lines in the buffer
+}
+
+// parseLineTarget reads a line target. Line numbers past either end of the
+// buffer are allowed and go no further than the first or last line.
+func parseLineTarget(input string) (lineTarget, error) {
+	var target lineTarget
+	spec := strings.TrimSpace(input)
+	switch {
+	case strings.HasPrefix(spec, "+"), strings.HasPrefix(spec, "-"):
+		// The sign is kept, strconv reads it as part of the number
+		target.relative = true
+	case strings.HasPrefix(spec, "%"):
+		target.percent = true
+		spec = spec[1:]
+	}
+
+	lineSpec, columnSpec, hasColumn := strings.Cut(spec, ":")
+	line, err := strconv.Atoi(lineSpec)
+	if err != nil || target.percent && line < 0 {
+		return target, fmt.Errorf("'%s' is not a line number", input)
+	}
+	target.line = line
+
+	if hasColumn {
+		column, err := strconv.Atoi(columnSpec)
+		if err != nil || column < 1 {
+			return target, fmt.Errorf("'%s' is not a column number", columnSpec)
+		}
+		target.column = column
+	}
+	return target, nil
+}
+
+// targetPosition returns where target is in the document, given the line
+// the cursor is on
+func (d *document) targetPosition(target lineTarget, cursorLine int) position {
+	last := max(d.buf.LineCount()-1, 0)
+
+	var line int
+	switch {
+	case target.relative:
+		line = cursorLine + target.line
+	case target.percent:
+		line = last * min(target.line, 100) / 100
+	default:
+		line = target.line - 1
+	}
+	line = max(min(line, last), 0)
+
+	col := 0
+	if target.column > 0 && line < d.buf.LineCount() {
+		col = characterOffset(d.buf.Line(line), target.column-1)
+	}
+	return position{line, col}
+}
+
+// characterOffset returns the offset of the grapheme cluster holding the
+// nth character of line, counting from 0, or the end of the line if it is
+// shorter. A tab counts as one character, as it does in compiler messages.
+func characterOffset(line string, n int) int {
+	chars := 0
+	for pos := 0; pos < len(line); {
+		next := nextGrapheme(line, pos)
+		chars += utf8.RuneCountInString(line[pos:next])
+		if chars > n {
+			return pos
+		}
+		pos = next
+	}
+	return len(line)
+}
+
+// jumpTo moves the cursor to pos, scrolling to put its line in the middle of
+// a pane height rows high if it is out of view
+func (s *viewState) jumpTo(pos position, height int) {
+	s.cursorY, s.cursorX = pos.line, pos.col
+	s.selecting = false
+	if pos.line < s.scrollY || pos.line >= s.scrollY+height {
+		s.scrollY = max(pos.line-height/2, 0)
+		s.scrollRow = 0
+	}
+}
+
+// enterGotoMode opens the go to line prompt in place of the search bar
+func (e *Editor) enterGotoMode() {
+	if e.searchMode {
+		e.exitSearchMode()
+	}
+	e.gotoMode = true
+	e.gotoInput = ""
+	e.gotoErr = ""
+	e.draw()
+}
+
+// handleGotoInput handles a key pressed while the go to line prompt is open.
+// Enter goes to the line typed, or reports why it can't be read and leaves
+// the prompt open to correct it.
+func (e *Editor) handleGotoInput(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		e.gotoMode = false
+
+	case tcell.KeyEnter:
+		if strings.TrimSpace(e.gotoInput) == "" {
+			e.gotoMode = false
+			return
+		}
+		target, err := parseLineTarget(e.gotoInput)
+		if err != nil {
+			e.gotoErr = err.Error()
+			return
+		}
+		e.gotoMode = false
+		e.jumpTo(e.targetPosition(target, e.cursorY), e.height)
+		e.ensureVisibleCursor()
+
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(e.gotoInput) > 0 {
+			_, size := utf8.DecodeLastRuneInString(e.gotoInput)
+			e.gotoInput = e.gotoInput[:len(e.gotoInput)-size]
+			e.gotoErr = ""
+		}
+
+	case tcell.KeyRune:
+		e.gotoInput += string(ev.Rune())
+		e.gotoErr = ""
+	}
+}
+
+// drawGotoInput draws the go to line prompt at the top of the screen, in
+// the style of the search bar
+func (e *Editor) drawGotoInput() {
+	width, _ := e.screen.Size()
+
+	// Input style
+	inputBgStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogForeground).
+		Background(e.theme.DialogBackground)
+
+	cursorStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogBackground).
+		Background(e.theme.DialogSelectedBackground)
+
+	iconStyle := tcell.StyleDefault.
+		Foreground(e.theme.StatusIconColor).
+		Background(e.theme.DialogBackground)
+
+	hintStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogButtonForeground).
+		Background(e.theme.DialogBackground)
+
+	for x := 0; x < width; x++ {
+		e.screen.SetContent(x, 0, ' ', nil, inputBgStyle)
+	}
+
+	// Draw prompt with icon, then the line being typed with a cursor
+	x := e.drawText(0, 0, string(e.theme.IconPosition), iconStyle)
+	x = e.drawText(x, 0, " Go to line: ", inputBgStyle)
+	x = e.drawText(x, 0, e.gotoInput, inputBgStyle)
+	e.screen.SetContent(x, 0, ' ', nil, cursorStyle)
+
+	// Explain a line that couldn't be read, or else what can be typed
+	if e.gotoErr != "" {
+		e.drawText(x+2, 0, e.gotoErr, inputBgStyle)
+		return
+	}
+	hint := "line[:column]  +lines  -lines  %percent "
+	if hintX := width - stringWidth(hint); hintX > x+2 {
+		e.drawText(hintX, 0, hint, hintStyle)
+	}
+}
+
+// fileLinePattern matches a file name followed by a line and column in the
+// form compilers print them, such as "main.go:120:15:"
+var fileLinePattern = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?:?$`)
+
+// parseFileArgs separates the files given on the command line from the lines
+// to open them at. A "+120" or "+120:15" applies to the file after it, or
+// after the last file to one given no line, and a file may be named as
+// "file.go:120:15" unless a file of that name exists. After "--", a name
+// starting with '+' is a file. The targets returned are nil for files
+// opened at the top.
+func parseFileArgs(args []string) ([]string, []*lineTarget, error) {
+	var paths []string
+	var targets []*lineTarget
+	var pending *lineTarget
+	var pendingArg string
+	filesOnly := false
+
+	for _, arg := range args {
+		if arg == "--" && !filesOnly {
+			filesOnly = true
+			continue
+		}
+		if strings.HasPrefix(arg, "+") && !filesOnly {
+			target, err := parseLineTarget(arg[1:])
+			if err != nil || target.relative || target.percent {
+				return nil, nil, fmt.Errorf("invalid line '%s'", arg)
+			}
+			pending, pendingArg = &target, arg
+			continue
+		}
+
+		path := arg
+		if _, err := os.Stat(arg); err != nil {
+			if m := fileLinePattern.FindStringSubmatch(arg); m != nil {
+				path = m[1]
+				target := lineTarget{}
+				target.line, _ = strconv.Atoi(m[2])
+				if m[3] != "" {
+					target.column, _ = strconv.Atoi(m[3])
+				}
+				if pending == nil {
+					pending = &target
+				}
+			}
+		}
+
+		paths = append(paths, path)
+		targets = append(targets, pending)
+		pending = nil
+	}
+
+	// A line with no file after it is for the last file, or the untitled
+	// buffer if there are none
+	if pending != nil {
+		switch last := len(paths) - 1; {
+		case last < 0:
+			paths, targets = []string{""}, []*lineTarget{pending}
+		case targets[last] == nil:
+			targets[last] = pending
+		default:
+			return nil, nil, fmt.Errorf("invalid line '%s', %s is already opened at a line", pendingArg, paths[last])
+		}
+	}
+	return paths, targets, nil
+}
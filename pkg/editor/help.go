@@ -0,0 +1,197 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// shortcuts are the commands shown in the shortcut bar, in the order they
+// are laid out: down each column, then across
+var shortcuts = []struct {
+	command string
+	label   string
+}{
+	{"help", "Help"},
+	{"quit", "Exit"},
+	{"save", "Save"},
+	{"palette", "Commands"},
+	{"find", "Find"},
+	{"replace", "Replace"},
+	{"cut", "Cut"},
+	{"paste", "Paste"},
+	{"copy", "Copy"},
+	{"buffers", "Buffers"},
+	{"undo", "Undo"},
+	{"redo", "Redo"},
+	{"goto-line", "Go To Line"},
+}
+
+// shortcutWidth is the narrowest a column of the shortcut bar gets before
+// shortcuts are left out
+const shortcutWidth = 14
+
+// drawShortcutBar draws two rows of commands with their keys, in the style
+// of nano, below the status line. Commands with no key are left out.
+func (e *Editor) drawShortcutBar(layout screenLayout) {
+	keyStyle := tcell.StyleDefault.
+		Foreground(e.theme.StatusForeground).
+		Background(e.theme.StatusBackground)
+
+	labelStyle := tcell.StyleDefault.
+		Foreground(e.theme.TextColor).
+		Background(e.theme.BackgroundColor)
+
+	type shortcut struct{ key, label string }
+	var items []shortcut
+	for _, s := range shortcuts {
+		if keys := e.keymap.keysFor(s.command); len(keys) > 0 {
+			items = append(items, shortcut{keyLabel(keys[0]), s.label})
+		}
+	}
+
+	// Fit as many columns of two as the width allows
+	columns := min((len(items)+1)/2, max(layout.width/shortcutWidth, 1))
+	if columns == 0 {
+		return
+	}
+	items = items[:min(len(items), columns*2)]
+	columnWidth := layout.width / columns
+
+	for i, item := range items {
+		x := (i / 2) * columnWidth
+		y := layout.shortcutY + i%2
+		x = e.drawText(x, y, item.key, keyStyle)
+		x = e.drawText(x, y, " ", labelStyle)
+		for _, r := range item.label {
+			if x >= (i/2+1)*columnWidth-1 {
+				break
+			}
+			x = e.drawText(x, y, string(r), labelStyle)
+		}
+	}
+}
+
+// toggleShortcutBar shows or hides the shortcut bar
+func (e *Editor) toggleShortcutBar() {
+	e.shortcutBar = !e.shortcutBar
+}
+
+// helpText returns the lines of the help screen: every command with the
+// keys bound to it, followed by the keys used inside the search bar
+func (e *Editor) helpText() []string {
+	lines := []string{
+		"Every command and the keys it is bound to. Keys can be changed in",
+		"the [keys] section of config/config.conf; commands with no key can",
+		"still be run from the command palette.",
+		"",
+	}
+
+	// Line the columns up on the widest keys and name
+	keys := make([]string, len(commands))
+	keysWidth, nameWidth := 0, 0
+	for i, cmd := range commands {
+		var labels []string
+		for _, key := range e.keymap.keysFor(cmd.name) {
+			labels = append(labels, keyLabel(key))
+		}
+		keys[i] = strings.Join(labels, " ")
+		keysWidth = max(keysWidth, stringWidth(keys[i]))
+		nameWidth = max(nameWidth, stringWidth(cmd.name))
+	}
+	for i, cmd := range commands {
+		lines = append(lines, fmt.Sprintf("  %-*s  %-*s  %s", keysWidth, keys[i], nameWidth, cmd.name, cmd.description))
+	}
+
+	return append(lines,
+		"",
+		"Hold Shift with a movement key to select text.",
+		"",
+		"In the search bar:",
+		"  Enter  Go to the next match",
+		"  M-C    Toggle case-sensitive search",
+		"  M-W    Toggle whole-word search",
+		"  M-R    Toggle regular expression search",
+		"  Esc    Close the search bar",
+		"",
+		"When replacing:",
+		"  y  Replace this match     n  Skip this match",
+		"  a  Replace all the rest   q  Stop replacing",
+		"",
+		"Going to a line:",
+		"  120     Line 120             120:15  Line 120, column 15",
+		"  +50     50 lines down        -10     10 lines up",
+		"  %50     Halfway down the buffer",
+	)
+}
+
+// showHelp shows the help screen over the whole editor until it is closed
+// with Esc, q or the help key. Long help scrolls with the movement keys.
+func (e *Editor) showHelp() {
+	lines := e.helpText()
+	scroll := 0
+
+	titleStyle := tcell.StyleDefault.
+		Foreground(e.theme.StatusForeground).
+		Background(e.theme.StatusBackground)
+
+	textStyle := tcell.StyleDefault.
+		Foreground(e.theme.TextColor).
+		Background(e.theme.BackgroundColor)
+
+	for {
+		width, height := e.screen.Size()
+		pageHeight := max(height-2, 1)
+		scroll = max(min(scroll, len(lines)-pageHeight), 0)
+
+		e.screen.Clear()
+
+		// Title on the top row, how to leave on the bottom row
+		for x := 0; x < width; x++ {
+			e.screen.SetContent(x, 0, ' ', nil, titleStyle)
+			e.screen.SetContent(x, height-1, ' ', nil, titleStyle)
+		}
+		e.drawText(1, 0, "pow help", titleStyle)
+		footer := fmt.Sprintf("Up/Down/PgUp/PgDn scroll   Esc close   %d-%d/%d",
+			scroll+1, min(scroll+pageHeight, len(lines)), len(lines))
+		e.drawText(1, height-1, footer, titleStyle)
+
+		for row := 0; row < pageHeight && scroll+row < len(lines); row++ {
+			e.drawTextClipped(0, row+1, width, lines[scroll+row], textStyle)
+		}
+
+		e.screen.Show()
+
+		// Handle input
+		ev := e.pollEvent()
+		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			e.screen.Sync()
+		case *tcell.EventKey:
+			if cmd := e.keymap.lookup(ev); cmd != nil && cmd.name == "help" {
+				return
+			}
+			switch ev.Key() {
+			case tcell.KeyUp:
+				scroll--
+			case tcell.KeyDown:
+				scroll++
+			case tcell.KeyPgUp:
+				scroll -= pageHeight
+			case tcell.KeyPgDn:
+				scroll += pageHeight
+			case tcell.KeyHome:
+				scroll = 0
+			case tcell.KeyEnd:
+				scroll = len(lines)
+			case tcell.KeyEscape, tcell.KeyEnter:
+				return
+			case tcell.KeyRune:
+				if ev.Rune() == 'q' {
+					return
+				}
+			}
+		}
+	}
+}
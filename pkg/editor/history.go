@@ -0,0 +1,254 @@
+package editor
+
+import "strings"
+
+// position identifies a location in the content as a line index and column
+type position struct {
+	line int
+	col  int
+}
+
+// editKind distinguishes the two primitive content operations
+type editKind int
+
+const (
+	editInsert editKind = iota
+	editDelete
+)
+
+// edit is a single primitive change to the content
+type edit struct {
+	kind editKind
+	pos  position
+	text string
+}
+
+// undoGroup tags steps that may absorb the next edit of the same kind, so
+// that a run of typing is undone in one go rather than a character at a time
+type undoGroup int
+
+const (
+	groupNone undoGroup = iota
+	groupTyping
+	groupBackspace
+	groupDelete
+)
+
+// undoStep is one entry in the history, reverted and reapplied as a unit
+type undoStep struct {
+	id           int
+	edits        []edit
+	group        undoGroup
+	cursorBefore position
+	cursorAfter  position
+}
+
+// history holds the undo and redo stacks of an editor
+type history struct {
+	undo   []*undoStep
+	redo   []*undoStep
+	nextID int
+
+	// sealed stops the top step from absorbing further edits
+	sealed bool
+
+	// pending collects edits while a transaction is open
+	pending *undoStep
+	depth   int
+}
+
+// newHistory creates an empty history
+func newHistory() *history {
+	return &history{nextID: 1}
+}
+
+// state returns an identifier for the current point in the history. It is 0
+// for the state the content was loaded in.
+func (h *history) state() int {
+	if len(h.undo) == 0 {
+		return 0
+	}
+	return h.undo[len(h.undo)-1].id
+}
+
+// seal prevents the next edit from being merged into the current top step
+func (h *history) seal() {
+	h.sealed = true
+}
+
+// begin opens a transaction; all edits recorded until the matching end are
+// undone as a single step. Transactions may be nested.
+func (h *history) begin(cursor position) {
+	if h.depth == 0 {
+		h.pending = &undoStep{cursorBefore: cursor, cursorAfter: cursor}
+	}
+	h.depth++
+}
+
+// end closes a transaction opened with begin
+func (h *history) end(cursor position) {
+	if h.depth == 0 {
+		return
+	}
+	h.depth--
+	if h.depth > 0 {
+		return
+	}
+
+	step := h.pending
+	h.pending = nil
+	if len(step.edits) == 0 {
+		return
+	}
+	step.cursorAfter = cursor
+	h.push(step)
+}
+
+// record adds an edit to the history, merging it into the previous step when
+// both belong to the same uninterrupted group
+func (h *history) record(ed edit, group undoGroup, before, after position) {
+	h.redo = nil
+
+	if h.pending != nil {
+		h.pending.edits = append(h.pending.edits, ed)
+		h.pending.cursorAfter = after
+		return
+	}
+
+	if len(h.undo) > 0 && !h.sealed && group != groupNone {
+		top := h.undo[len(h.undo)-1]
+		if top.group == group && top.absorbs(ed) {
+			top.edits = append(top.edits, ed)
+			top.cursorAfter = after
+			return
+		}
+	}
+
+	h.push(&undoStep{
+		edits:        []edit{ed},
+		group:        group,
+		cursorBefore: before,
+		cursorAfter:  after,
+	})
+}
+
+// push appends a finished step to the undo stack
+func (h *history) push(step *undoStep) {
+	step.id = h.nextID
+	h.nextID++
+	h.undo = append(h.undo, step)
+	h.redo = nil
+	h.sealed = false
+}
+
+// popUndo removes the most recent step and moves it onto the redo stack
+func (h *history) popUndo() *undoStep {
+	if len(h.undo) == 0 {
+		return nil
+	}
+	step := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, step)
+	h.sealed = true
+	return step
+}
+
+// popRedo removes the most recently undone step and moves it back onto the
+// undo stack
+func (h *history) popRedo() *undoStep {
+	if len(h.redo) == 0 {
+		return nil
+	}
+	step := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, step)
+	h.sealed = true
+	return step
+}
+
+// absorbs reports whether ed directly continues the last edit of the step
+func (s *undoStep) absorbs(ed edit) bool {
+	if len(s.edits) == 0 || strings.Contains(ed.text, "\n") {
+		return false
+	}
+	last := s.edits[len(s.edits)-1]
+	if last.kind != ed.kind {
+		return false
+	}
+
+	switch s.group {
+	case groupTyping:
+		return ed.pos == textEnd(last.pos, last.text)
+	case groupBackspace:
+		return textEnd(ed.pos, ed.text) == last.pos
+	case groupDelete:
+		return ed.pos == last.pos
+	}
+	return false
+}
+
+// textEnd returns the position just past text when it is inserted at pos
+func textEnd(pos position, text string) position {
+	newlines := strings.Count(text, "\n")
+	if newlines == 0 {
+		return position{pos.line, pos.col + len(text)}
+	}
+	return position{pos.line + newlines, len(text) - strings.LastIndex(text, "\n") - 1}
+}
+
+// undo reverts the most recent step in the history
+func (e *Editor) undo() {
+	step := e.history.popUndo()
+	if step == nil {
+		return
+	}
+
+	for i := len(step.edits) - 1; i >= 0; i-- {
+		ed := step.edits[i]
+		switch ed.kind {
+		case editInsert:
+			e.rawDelete(ed.pos, textEnd(ed.pos, ed.text))
+		case editDelete:
+			e.rawInsert(ed.pos, ed.text)
+		}
+	}
+
+	e.setCursor(step.cursorBefore)
+	e.updateModified()
+}
+
+// redo reapplies the most recently undone step
+func (e *Editor) redo() {
+	step := e.history.popRedo()
+	if step == nil {
+		return
+	}
+
+	for _, ed := range step.edits {
+		switch ed.kind {
+		case editInsert:
+			e.rawInsert(ed.pos, ed.text)
+		case editDelete:
+			e.rawDelete(ed.pos, textEnd(ed.pos, ed.text))
+		}
+	}
+
+	e.setCursor(step.cursorAfter)
+	e.updateModified()
+}
+
+// updateModified recomputes the modified flag from the history position
+// and the file format. Edits in an open transaction are not a step in the
+// history yet but still count as a change.
+func (e *Editor) updateModified() {
+	pending := e.history.pending != nil && len(e.history.pending.edits) > 0
+	e.modified = pending || e.history.state() != e.savedState || e.format != e.savedFormat
+}
+
+// markSaved records the current history position as the saved state
+func (e *Editor) markSaved() {
+	e.history.seal()
+	e.savedState = e.history.state()
+	e.savedFormat = e.format
+	e.modified = false
+}
@@ -0,0 +1,348 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+
+	"pow/pkg/config"
+)
+
+// Keys are named as written in the config file: lower case modifiers in the
+// order ctrl, alt, shift, then the key itself, joined with '+', such as
+// "ctrl+s", "alt+," or "shift+up".
+
+// specialKey names a key that isn't a character
+type specialKey struct {
+	key   tcell.Key
+	name  string
+	label string // as shown to the user
+}
+
+var specialKeys = []specialKey{
+	{tcell.KeyUp, "up", "Up"},
+	{tcell.KeyDown, "down", "Down"},
+	{tcell.KeyLeft, "left", "Left"},
+	{tcell.KeyRight, "right", "Right"},
+	{tcell.KeyHome, "home", "Home"},
+	{tcell.KeyEnd, "end", "End"},
+	{tcell.KeyPgUp, "pgup", "PgUp"},
+	{tcell.KeyPgDn, "pgdn", "PgDn"},
+	{tcell.KeyInsert, "insert", "Ins"},
+	{tcell.KeyDelete, "delete", "Del"},
+	{tcell.KeyEnter, "enter", "Enter"},
+	{tcell.KeyTab, "tab", "Tab"},
+	{tcell.KeyBacktab, "backtab", "Shift+Tab"},
+	{tcell.KeyEscape, "esc", "Esc"},
+	{tcell.KeyBackspace2, "backspace", "Bksp"},
+	{tcell.KeyF1, "f1", "F1"},
+	{tcell.KeyF2, "f2", "F2"},
+	{tcell.KeyF3, "f3", "F3"},
+	{tcell.KeyF4, "f4", "F4"},
+	{tcell.KeyF5, "f5", "F5"},
+	{tcell.KeyF6, "f6", "F6"},
+	{tcell.KeyF7, "f7", "F7"},
+	{tcell.KeyF8, "f8", "F8"},
+	{tcell.KeyF9, "f9", "F9"},
+	{tcell.KeyF10, "f10", "F10"},
+	{tcell.KeyF11, "f11", "F11"},
+	{tcell.KeyF12, "f12", "F12"},
+}
+
+// keyAliases are other names accepted for special keys in the config
+var keyAliases = map[string]string{
+	"pageup":   "pgup",
+	"pagedown": "pgdn",
+	"escape":   "esc",
+	"del":      "delete",
+	"ins":      "insert",
+	"return":   "enter",
+}
+
+// keyName returns the name of the key pressed in ev
+func keyName(ev *tcell.EventKey) string {
+	key, mods := ev.Key(), ev.Modifiers()
+	ctrl := mods&tcell.ModCtrl != 0
+	var base string
+
+	switch {
+	case key == tcell.KeyRune:
+		base = string(ev.Rune())
+		if ev.Rune() == ' ' {
+			base = "space"
+		}
+		// Shift is already part of the character typed
+		mods &^= tcell.ModShift
+	case key == tcell.KeyBackspace:
+		// Terminals send one of two codes for Backspace
+		base = "backspace"
+	case key == tcell.KeyCtrlSpace:
+		base, ctrl = "space", true
+	default:
+		for _, special := range specialKeys {
+			if special.key == key {
+				base = special.name
+				break
+			}
+		}
+		if base != "" {
+			break
+		}
+
+		// The remaining control codes are Ctrl with a letter or symbol
+		if key >= tcell.KeyCtrlA && key <= tcell.KeyCtrlUnderscore {
+			base, ctrl = strings.ToLower(string(rune(key)+'@')), true
+		} else {
+			return ""
+		}
+	}
+
+	name := ""
+	if ctrl {
+		name += "ctrl+"
+	}
+	if mods&tcell.ModAlt != 0 {
+		name += "alt+"
+	}
+	if mods&tcell.ModShift != 0 {
+		name += "shift+"
+	}
+	return name + base
+}
+
+// parseKey turns a key as written in the config file into its name.
+// Modifiers may be given in any order and case.
+func parseKey(spec string) (string, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(spec)), "+")
+	if strings.HasSuffix(spec, "++") {
+		// The plus key itself
+		parts = append(parts[:len(parts)-2], "+")
+	}
+
+	var mods tcell.ModMask
+	for _, part := range parts[:len(parts)-1] {
+		switch part {
+		case "ctrl", "control":
+			mods |= tcell.ModCtrl
+		case "alt", "meta":
+			mods |= tcell.ModAlt
+		case "shift":
+			mods |= tcell.ModShift
+		default:
+			return "", fmt.Errorf("unknown modifier '%s' in '%s'", part, spec)
+		}
+	}
+	base := parts[len(parts)-1]
+	if alias, ok := keyAliases[base]; ok {
+		base = alias
+	}
+
+	var ev *tcell.EventKey
+	for _, special := range specialKeys {
+		if special.name == base {
+			ev = tcell.NewEventKey(special.key, 0, mods)
+		}
+	}
+
+	if ev == nil {
+		r := []rune(base)
+		if base == "space" {
+			r = []rune{' '}
+		}
+		if len(r) != 1 {
+			return "", fmt.Errorf("unknown key '%s'", spec)
+		}
+
+		switch {
+		case mods&tcell.ModCtrl != 0:
+			// Only letters and a few symbols can be typed with Ctrl
+			code := unicode.ToUpper(r[0])
+			if code == ' ' {
+				code = '@'
+			}
+			if code < '@' || code > '_' {
+				return "", fmt.Errorf("'%s' cannot be typed in a terminal", spec)
+			}
+			ev = tcell.NewEventKey(tcell.Key(code-'@'), 0, mods)
+
+			// Some send the same code as another key
+			if name := keyName(ev); !strings.HasSuffix(name, "+"+strings.ToLower(string(code))) && code != '@' {
+				return "", fmt.Errorf("'%s' is sent as %s by terminals", spec, strings.TrimPrefix(name, "ctrl+"))
+			}
+		case mods&tcell.ModAlt != 0:
+			if mods&tcell.ModShift != 0 {
+				r[0] = unicode.ToUpper(r[0])
+			}
+			ev = tcell.NewEventKey(tcell.KeyRune, r[0], mods)
+		default:
+			return "", fmt.Errorf("'%s' is typed as text; add ctrl or alt to bind it", spec)
+		}
+	}
+
+	return keyName(ev), nil
+}
+
+// keyLabel returns a key name in the short form shown in the status bar
+// and help, such as "^S" for ctrl+s and "M-H" for alt+h
+func keyLabel(name string) string {
+	mods, base := "", name
+	if i := strings.LastIndex(name[:len(name)-1], "+"); i >= 0 {
+		mods, base = name[:i+1], name[i+1:]
+	}
+
+	if len([]rune(base)) == 1 {
+		switch mods {
+		case "ctrl+":
+			return "^" + strings.ToUpper(base)
+		case "alt+":
+			return "M-" + strings.ToUpper(base)
+		}
+	}
+
+	label := strings.ToUpper(base)
+	for _, special := range specialKeys {
+		if special.name == base {
+			label = special.label
+		}
+	}
+	if base == "space" {
+		label = "Space"
+	}
+	for _, mod := range []string{"shift", "alt", "ctrl"} {
+		if strings.Contains(mods, mod+"+") {
+			label = strings.ToUpper(mod[:1]) + mod[1:] + "+" + label
+		}
+	}
+	return label
+}
+
+// keymap maps key names to the commands they run
+type keymap map[string]*command
+
+// newKeymap binds the default keys of every command, then applies the
+// bindings from the config file. Invalid bindings and conflicts between
+// them are returned as problems to show the user once the editor is up.
+func newKeymap(bindings []config.KeyBinding, configPath string) (keymap, []string) {
+	km := keymap{}
+	var problems []string
+	for i := range commands {
+		for _, key := range commands[i].keys {
+			km[key] = &commands[i]
+		}
+	}
+
+	// Keys bound in the config file so far, to catch one key bound twice
+	boundAt := map[string]config.KeyBinding{}
+
+	for _, binding := range bindings {
+		key, err := parseKey(binding.Key)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Invalid key binding in '%s' line %d: %v", configPath, binding.Line, err))
+			continue
+		}
+
+		var cmd *command
+		if binding.Command != "none" {
+			if cmd = findCommand(binding.Command); cmd == nil {
+				problems = append(problems, fmt.Sprintf("Unknown command '%s' in '%s' line %d", binding.Command, configPath, binding.Line))
+				continue
+			}
+		}
+
+		if earlier, ok := boundAt[key]; ok && earlier.Command != binding.Command {
+			problems = append(problems, fmt.Sprintf("Key conflict in '%s': %s is bound to %s on line %d and to %s on line %d, using %s",
+				configPath, key, earlier.Command, earlier.Line, binding.Command, binding.Line, binding.Command))
+		}
+		boundAt[key] = binding
+
+		if cmd == nil {
+			delete(km, key)
+		} else {
+			km[key] = cmd
+		}
+	}
+
+	// A default key taken for another command may leave a command with no
+	// key at all
+	reported := map[*command]bool{}
+	for _, binding := range bindings {
+		previous, ok := defaultCommandFor(binding.Key)
+		if !ok || binding.Command == "none" || reported[previous] || len(km.keysFor(previous.name)) > 0 {
+			continue
+		}
+		reported[previous] = true
+		problems = append(problems, fmt.Sprintf("Key conflict in '%s' line %d: %s no longer has a key",
+			configPath, binding.Line, previous.name))
+	}
+
+	return km, problems
+}
+
+// defaultCommandFor returns the command bound to a key by default
+func defaultCommandFor(spec string) (*command, bool) {
+	key, err := parseKey(spec)
+	if err != nil {
+		return nil, false
+	}
+	for i := range commands {
+		for _, k := range commands[i].keys {
+			if k == key {
+				return &commands[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// keysFor returns the keys bound to the named command, those bound by
+// default first
+func (km keymap) keysFor(name string) []string {
+	cmd := findCommand(name)
+	if cmd == nil {
+		return nil
+	}
+
+	var keys []string
+	for _, key := range cmd.keys {
+		if km[key] == cmd {
+			keys = append(keys, key)
+		}
+	}
+	var extra []string
+	for key, bound := range km {
+		if bound == cmd && !containsString(cmd.keys, key) {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+	return append(keys, extra...)
+}
+
+// lookup returns the command bound to the key pressed in ev. A Shift+key
+// with no binding of its own runs the command for the plain key if that
+// command moves the cursor, so that Shift extends the selection.
+func (km keymap) lookup(ev *tcell.EventKey) *command {
+	if cmd, ok := km[keyName(ev)]; ok {
+		return cmd
+	}
+	if ev.Modifiers()&tcell.ModShift != 0 && ev.Key() != tcell.KeyRune {
+		plain := tcell.NewEventKey(ev.Key(), ev.Rune(), ev.Modifiers()&^tcell.ModShift)
+		if cmd, ok := km[keyName(plain)]; ok && cmd.movement {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// containsString reports whether list holds s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"pow/pkg/config"
+	"pow/pkg/syntax"
+)
+
+// Options change how the editor starts, as given by command line flags. The
+// zero value starts the editor as the config file says.
+type Options struct {
+	ConfigPath string // main config file, config/config.conf when empty
+	Theme      string // theme to use in place of the one in the config file
+	ReadOnly   bool   // refuse to change or save any buffer
+	Line       string // line to open the first file at, as "120" or "120:15"
+	Syntax     string // language to highlight every buffer as, such as "go"
+}
+
+// defaultConfigPath is where the config file is looked for unless another
+// is given
+var defaultConfigPath = filepath.Join("config", "config.conf")
+
+// configPath returns the config file to read
+func (o Options) configPath() string {
+	if o.ConfigPath == "" {
+		return defaultConfigPath
+	}
+	return o.ConfigPath
+}
+
+// Validate reports the first problem with the options or the file arguments,
+// so that it can be shown before the editor takes over the terminal
+func (o Options) Validate(args ...string) error {
+	if o.ConfigPath != "" {
+		if _, err := os.Stat(o.ConfigPath); err != nil {
+			return fmt.Errorf("config file '%s' not found", o.ConfigPath)
+		}
+	}
+	if o.Theme != "" {
+		if _, err := config.FindTheme(o.configPath(), o.Theme); err != nil {
+			return err
+		}
+	}
+	if o.Syntax != "" && syntax.NewHighlighterForLanguage(o.Syntax) == nil {
+		return fmt.Errorf("unknown syntax '%s'", o.Syntax)
+	}
+	if o.Line != "" {
+		if _, _, err := parseFileArgs([]string{"+" + o.Line}); err != nil {
+			return fmt.Errorf("invalid line '%s'", o.Line)
+		}
+	}
+	_, _, err := parseFileArgs(o.fileArgs(args))
+	return err
+}
+
+// fileArgs returns the file arguments with the line option put in front of
+// the first file, as if given as "+line"
+func (o Options) fileArgs(args []string) []string {
+	if o.Line == "" {
+		return args
+	}
+	return append([]string{"+" + o.Line}, args...)
+}
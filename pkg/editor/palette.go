@@ -0,0 +1,200 @@
+package editor
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// fuzzyScore reports whether the characters of query appear in text in the
+// same order, ignoring case, and scores the match. Characters at the start
+// of a word and runs of consecutive characters score higher.
+func fuzzyScore(query, text string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	t := []rune(strings.ToLower(text))
+
+	score, qi, prev := 0, 0, -2
+	for ti := 0; ti < len(t) && qi < len(q); ti++ {
+		if t[ti] != q[qi] {
+			continue
+		}
+		score++
+		if ti == prev+1 {
+			score += 2
+		}
+		if ti == 0 || !unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1]) {
+			score += 3
+		}
+		prev = ti
+		qi++
+	}
+	return score, qi == len(q)
+}
+
+// matchCommands returns the commands matching query, best first. A match in
+// the name counts for more than one in the description.
+func matchCommands(query string) []*command {
+	type match struct {
+		cmd   *command
+		score int
+	}
+	var matches []match
+	for i := range commands {
+		cmd := &commands[i]
+		if cmd.name == "palette" {
+			continue
+		}
+
+		nameScore, nameOK := fuzzyScore(query, cmd.name)
+		descScore, descOK := fuzzyScore(query, cmd.description)
+		switch {
+		case nameOK:
+			matches = append(matches, match{cmd, 2*nameScore + 1})
+		case descOK:
+			matches = append(matches, match{cmd, descScore})
+		}
+	}
+
+	// Commands that score the same stay in their usual order
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	result := make([]*command, len(matches))
+	for i, m := range matches {
+		result[i] = m.cmd
+	}
+	return result
+}
+
+// commandPalette lists every command with its keys in a dialog, narrowed
+// down as the user types, and runs the one chosen with Enter
+func (e *Editor) commandPalette() {
+	width, height := e.screen.Size()
+	query := ""
+	selected := 0
+	scroll := 0
+
+	// Dialog dimensions, with room for the query and one row per command
+	dialogWidth := min(72, width-4)
+	listHeight := min(len(commands), max(height-10, 1))
+	dialogHeight := listHeight + 6
+	dialogX := (width - dialogWidth) / 2
+	dialogY := (height - dialogHeight) / 2
+
+	// Create styles
+	dialogStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogForeground).
+		Background(e.theme.DialogBackground)
+
+	inputStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogButtonForeground).
+		Background(e.theme.DialogButtonBackground)
+
+	keyStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogBorderColor).
+		Background(e.theme.DialogBackground)
+
+	selectedStyle := tcell.StyleDefault.
+		Foreground(e.theme.DialogSelectedForeground).
+		Background(e.theme.DialogSelectedBackground)
+
+	for {
+		matches := matchCommands(query)
+		selected = min(selected, max(len(matches)-1, 0))
+
+		// Keep the selected command within the visible rows
+		if selected < scroll {
+			scroll = selected
+		} else if selected >= scroll+listHeight {
+			scroll = selected - listHeight + 1
+		}
+
+		e.drawDialogFrame(dialogX, dialogY, dialogWidth, dialogHeight, "Commands")
+
+		// Draw the query being typed, with a cursor
+		inputY := dialogY + 2
+		for x := dialogX + 2; x < dialogX+dialogWidth-2; x++ {
+			e.screen.SetContent(x, inputY, ' ', nil, inputStyle)
+		}
+		inputX := e.drawText(dialogX+2, inputY, string(e.theme.IconFind)+" ", inputStyle)
+		inputX = e.drawText(inputX, inputY, query, inputStyle)
+		e.screen.SetContent(inputX, inputY, ' ', nil, selectedStyle)
+
+		// Draw the matching commands, with their keys on the right
+		for row := 0; row < listHeight; row++ {
+			idx := scroll + row
+			if idx >= len(matches) {
+				break
+			}
+			cmd := matches[idx]
+
+			style, keysStyle := dialogStyle, keyStyle
+			if idx == selected {
+				style, keysStyle = selectedStyle, selectedStyle
+			}
+
+			y := dialogY + 4 + row
+			for x := dialogX + 2; x < dialogX+dialogWidth-2; x++ {
+				e.screen.SetContent(x, y, ' ', nil, style)
+			}
+
+			var labels []string
+			for _, key := range e.keymap.keysFor(cmd.name) {
+				labels = append(labels, keyLabel(key))
+			}
+			keys := strings.Join(labels, " ")
+			keysX := dialogX + dialogWidth - 3 - stringWidth(keys)
+
+			entry := cmd.name + "  " + cmd.description
+			e.drawTextClipped(dialogX+3, y, keysX-1, entry, style)
+			e.drawText(keysX, y, keys, keysStyle)
+		}
+		if len(matches) == 0 {
+			e.drawText(dialogX+3, dialogY+4, "No matching commands", dialogStyle)
+		}
+
+		e.screen.Show()
+
+		// Handle input
+		ev := e.pollEvent()
+		switch ev := ev.(type) {
+		case *tcell.EventKey:
+			switch ev.Key() {
+			case tcell.KeyUp:
+				if len(matches) > 0 {
+					selected = (selected + len(matches) - 1) % len(matches)
+				}
+			case tcell.KeyDown:
+				if len(matches) > 0 {
+					selected = (selected + 1) % len(matches)
+				}
+			case tcell.KeyEnter:
+				if len(matches) == 0 {
+					continue
+				}
+				// Run the command with the editor drawn again behind it
+				e.draw()
+				e.runCommand(matches[selected])
+				return
+			case tcell.KeyEscape:
+				return
+			case tcell.KeyBackspace, tcell.KeyBackspace2:
+				if len(query) > 0 {
+					_, size := utf8.DecodeLastRuneInString(query)
+					query = query[:len(query)-size]
+					selected = 0
+				}
+			case tcell.KeyRune:
+				// Only add the character if it would fit in the input
+				if inputX < dialogX+dialogWidth-3 {
+					query += string(ev.Rune())
+					selected = 0
+				}
+			}
+		}
+	}
+}
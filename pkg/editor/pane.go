@@ -0,0 +1,144 @@
+package editor
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// pane is a node in the screen layout. A leaf shows a single view; any other
+// pane is split in two, either stacked or side by side.
+type pane struct {
+	view *view // set for a leaf
+
+	vertical bool // children side by side rather than stacked
+	first    *pane
+	second   *pane
+	parent   *pane
+}
+
+// views returns the views in the pane in screen order
+func (p *pane) views() []*view {
+	if p.view != nil {
+		return []*view{p.view}
+	}
+	return append(p.first.views(), p.second.views()...)
+}
+
+// find returns the leaf showing v
+func (p *pane) find(v *view) *pane {
+	if p.view != nil {
+		if p.view == v {
+			return p
+		}
+		return nil
+	}
+	if found := p.first.find(v); found != nil {
+		return found
+	}
+	return p.second.find(v)
+}
+
+// splitPane splits the focused pane in two, stacked or side by side, and
+// moves focus to the new pane. Both panes start out showing the same place
+// in the same document.
+func (e *Editor) splitPane(vertical bool) {
+	leaf := e.root.find(e.view)
+	newView := &view{document: e.document, viewState: e.viewState}
+
+	*leaf = pane{
+		vertical: vertical,
+		first:    &pane{view: e.view},
+		second:   &pane{view: newView},
+		parent:   leaf.parent,
+	}
+	leaf.first.parent = leaf
+	leaf.second.parent = leaf
+
+	e.view = newView
+}
+
+// closePane removes the focused pane and gives its space to its sibling.
+// The last pane cannot be closed.
+func (e *Editor) closePane() {
+	leaf := e.root.find(e.view)
+	parent := leaf.parent
+	if parent == nil {
+		e.showMessage("Cannot close the only pane")
+		return
+	}
+
+	// Remember where the view left its document for the next time it is shown
+	e.document.state = e.viewState
+
+	sibling := parent.first
+	if sibling == leaf {
+		sibling = parent.second
+	}
+
+	// The sibling takes the place of the split
+	*parent = pane{
+		view:     sibling.view,
+		vertical: sibling.vertical,
+		first:    sibling.first,
+		second:   sibling.second,
+		parent:   parent.parent,
+	}
+	if parent.view == nil {
+		parent.first.parent = parent
+		parent.second.parent = parent
+	}
+
+	e.view = parent.views()[0]
+}
+
+// cyclePane moves focus delta panes forward or back, wrapping around
+func (e *Editor) cyclePane(delta int) {
+	views := e.root.views()
+	for idx, v := range views {
+		if v == e.view {
+			n := len(views)
+			e.view = views[((idx+delta)%n+n)%n]
+			return
+		}
+	}
+}
+
+// drawLayout draws the panes of p into the given screen area. Pane drawing
+// works on the current view, so each view is made current while it is
+// drawn; the caller restores the focused view afterwards.
+func (e *Editor) drawLayout(p *pane, focus *view, x, y, width, height int) {
+	if p.view != nil {
+		p.view.x, p.view.y = x, y
+		p.view.width, p.view.height = max(width, 1), max(height, 1)
+		e.view = p.view
+		e.drawPane(focus)
+		return
+	}
+
+	dividerStyle := tcell.StyleDefault.
+		Foreground(e.theme.StatusIconColor).
+		Background(e.theme.StatusBackground)
+
+	if p.vertical {
+		// Side by side with a divider column between the panes
+		firstWidth := (width - 1) / 2
+		e.drawLayout(p.first, focus, x, y, firstWidth, height)
+		for row := y; row < y+height; row++ {
+			e.screen.SetContent(x+firstWidth, row, '│', nil, dividerStyle)
+		}
+		e.drawLayout(p.second, focus, x+firstWidth+1, y, width-firstWidth-1, height)
+		return
+	}
+
+	// Stacked, with a divider row naming the document in the pane above
+	firstHeight := (height - 1) / 2
+	e.drawLayout(p.first, focus, x, y, width, firstHeight)
+	for col := x; col < x+width; col++ {
+		e.screen.SetContent(col, y+firstHeight, '─', nil, dividerStyle)
+	}
+	above := p.first.views()
+	label := fmt.Sprintf(" %s ", above[len(above)-1].name())
+	e.drawTextClipped(x+1, y+firstHeight, x+width, label, dividerStyle)
+	e.drawLayout(p.second, focus, x, y+firstHeight+1, width, height-firstHeight-1)
+}
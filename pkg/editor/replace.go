@@ -0,0 +1,215 @@
+package editor
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// replaceStage tracks which part of a search-and-replace the user is in
+type replaceStage int
+
+const (
+	replaceOff     replaceStage = iota
+	replaceFind                 // entering the text to find
+	replaceWith                 // entering the replacement
+	replaceConfirm              // stepping through the matches
+)
+
+// enterReplaceMode starts a search-and-replace from the cursor
+func (e *Editor) enterReplaceMode() {
+	e.replaceOrigin = e.cursorPos()
+	e.enterSearchMode()
+	e.replaceStage = replaceFind
+	e.replaceText = ""
+	e.replaceCount = 0
+}
+
+// handleReplaceInput handles keyboard input while entering the replacement
+// or confirming matches. It returns false once the key has been consumed.
+func (e *Editor) handleReplaceInput(ev *tcell.EventKey) bool {
+	if ev.Key() == tcell.KeyEscape {
+		e.finishReplace()
+		return false
+	}
+
+	switch e.replaceStage {
+	case replaceFind:
+		if ev.Key() != tcell.KeyEnter {
+			return e.handleSearchKey(ev)
+		}
+		if e.searchQuery != "" {
+			e.replaceStage = replaceWith
+		}
+
+	case replaceWith:
+		switch ev.Key() {
+		case tcell.KeyEnter:
+			e.startConfirm()
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			if len(e.replaceText) > 0 {
+				_, size := utf8.DecodeLastRuneInString(e.replaceText)
+				e.replaceText = e.replaceText[:len(e.replaceText)-size]
+			}
+		case tcell.KeyRune:
+			e.replaceText += string(ev.Rune())
+		}
+
+	case replaceConfirm:
+		if ev.Key() != tcell.KeyRune {
+			return false
+		}
+		// The matches may have gone with the text they were in
+		if len(e.searchResults) == 0 || e.currentSearchIdx < 0 {
+			e.finishReplace()
+			return false
+		}
+		switch ev.Rune() {
+		case 'y', 'Y':
+			e.history.begin(e.cursorPos())
+			e.replaceCurrent()
+			e.history.end(e.cursorPos())
+			e.history.seal()
+			e.nextReplaceMatch()
+		case 'n', 'N':
+			e.currentSearchIdx = (e.currentSearchIdx + 1) % len(e.searchResults)
+			e.replaceVisited++
+			e.nextReplaceMatch()
+		case 'a', 'A':
+			e.replaceRemaining()
+		case 'q', 'Q':
+			e.finishReplace()
+		}
+	}
+
+	return false
+}
+
+// startConfirm begins stepping through the matches, starting from the first
+// one at or after the cursor
+func (e *Editor) startConfirm() {
+	if len(e.searchResults) == 0 {
+		e.finishReplace()
+		e.showMessage("No matches found")
+		return
+	}
+
+	e.replaceStage = replaceConfirm
+	e.replaceVisited = 0
+	e.replaceTotal = len(e.searchResults)
+
+	e.currentSearchIdx = 0
+	for idx, result := range e.searchResults {
+		if result.Line > e.replaceOrigin.line ||
+			(result.Line == e.replaceOrigin.line && result.Col >= e.replaceOrigin.col) {
+			e.currentSearchIdx = idx
+			break
+		}
+	}
+	e.navigateToSearchResult(e.currentSearchIdx)
+}
+
+// nextReplaceMatch moves to the next match to confirm, or finishes once
+// every match has been visited
+func (e *Editor) nextReplaceMatch() {
+	if e.replaceVisited >= e.replaceTotal || len(e.searchResults) == 0 {
+		e.finishReplace()
+		return
+	}
+	e.navigateToSearchResult(e.currentSearchIdx)
+}
+
+// replaceRemaining replaces every match not yet visited as one undo step
+func (e *Editor) replaceRemaining() {
+	e.history.begin(e.cursorPos())
+	for e.replaceVisited < e.replaceTotal && len(e.searchResults) > 0 {
+		e.replaceCurrent()
+	}
+	e.history.end(e.cursorPos())
+	e.history.seal()
+	e.finishReplace()
+}
+
+// replaceCurrent replaces the current match, drops it from the results and
+// shifts the matches after it to account for the new text
+func (e *Editor) replaceCurrent() {
+	if len(e.searchResults) == 0 || e.currentSearchIdx < 0 {
+		e.finishReplace()
+		return
+	}
+	idx := e.currentSearchIdx
+	result := e.searchResults[idx]
+	replacement := e.replacementFor(result)
+
+	start := position{result.Line, result.Col}
+	e.deleteText(start, position{result.Line, result.Col + result.Len}, groupNone)
+	end := e.insertText(start, replacement, groupNone)
+	e.setCursor(end)
+
+	// Matches later on the same line move with the end of the replacement,
+	// matches on later lines move by the number of lines added
+	matchEnd := result.Col + result.Len
+	for i := range e.searchResults {
+		other := &e.searchResults[i]
+		if other.Line == result.Line && other.Col >= matchEnd {
+			other.Col = end.col + other.Col - matchEnd
+			other.Line = end.line
+		} else if other.Line > result.Line {
+			other.Line += end.line - start.line
+		}
+	}
+
+	e.searchResults = append(e.searchResults[:idx], e.searchResults[idx+1:]...)
+	if len(e.searchResults) > 0 {
+		e.currentSearchIdx = idx % len(e.searchResults)
+	}
+	e.replaceVisited++
+	e.replaceCount++
+}
+
+// replacementFor returns the text that replaces a match. In regex mode the
+// replacement may refer to capture groups as $1 or ${name}, filled in from
+// the groups found when searching, as the text around the match may have
+// changed since.
+func (e *Editor) replacementFor(result SearchResult) string {
+	if !e.searchRegex || e.searchPattern == nil {
+		return e.replaceText
+	}
+
+	match := e.line(result.Line)[result.Col : result.Col+result.Len]
+	return string(e.searchPattern.ExpandString(nil, e.replaceText, match, result.Groups))
+}
+
+// finishReplace leaves replace mode and reports how much was replaced. It
+// does nothing once replace mode is left, so a guard that has finished the
+// replace doesn't report it twice.
+func (e *Editor) finishReplace() {
+	if e.replaceStage == replaceOff {
+		return
+	}
+	confirming := e.replaceStage == replaceConfirm
+
+	e.replaceStage = replaceOff
+	e.searchResults = []SearchResult{}
+	e.currentSearchIdx = -1
+	e.exitSearchMode()
+	e.draw()
+
+	if confirming {
+		e.showMessage(fmt.Sprintf("Replaced %d occurrence(s)", e.replaceCount))
+	}
+}
+
+// replacePrompt returns the text shown in the search bar while replacing
+func (e *Editor) replacePrompt() string {
+	switch e.replaceStage {
+	case replaceFind:
+		return " Replace: "
+	case replaceWith:
+		return fmt.Sprintf(" Replace '%s' with: ", e.searchQuery)
+	case replaceConfirm:
+		return fmt.Sprintf(" Replace with '%s'? (y)es (n)o (a)ll (q)uit ", e.replaceText)
+	}
+	return " Search: "
+}
@@ -0,0 +1,145 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"pow/pkg/buffer"
+)
+
+// writeFile writes the buffer to a file in the given format. The content
+// goes to a temporary file in the same directory which is synced and then
+// renamed over the original, so a crash or a full disk never leaves the
+// file half written. Symlinks are followed so the link itself is kept, and
+// the original's permissions, owner and extended attributes are carried
+// over. When that isn't possible, such as in a directory the user can't
+// create files in, the file is overwritten in place instead.
+func writeFile(filePath string, buf *buffer.Buffer, format fileFormat) error {
+	// Save to the file a symlink points at rather than replacing the link
+	target, err := filepath.EvalSymlinks(filePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("cannot resolve '%s': %w", filePath, err)
+		}
+		target = filePath
+	}
+
+	info, err := os.Stat(target)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot access '%s': %w", target, err)
+	}
+	if info != nil && !info.Mode().IsRegular() {
+		return fmt.Errorf("'%s' is not a regular file", target)
+	}
+
+	// Renaming over a file only needs the directory to be writable, so ask
+	// for the file itself, as writing to it directly would
+	if info != nil {
+		file, err := os.OpenFile(target, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("cannot open '%s' for writing: %w", target, err)
+		}
+		file.Close()
+	}
+
+	write := func(w io.Writer) error {
+		_, err := buf.WriteTo(&formatWriter{w: w, format: format})
+		return err
+	}
+	err = writeAtomic(target, info, newFileMode(), write)
+	if errors.Is(err, errCannotReplace) {
+		return writeInPlace(target, write)
+	}
+	return err
+}
+
+// errCannotReplace reports that the file can't be replaced by a new one
+// without losing its ownership or because the directory is not writable
+var errCannotReplace = errors.New("file cannot be replaced")
+
+// writeAtomic writes content with write to a temporary file and renames it
+// over target. info describes the existing file, or is nil for a new one,
+// which is given mode.
+func writeAtomic(target string, info os.FileInfo, mode os.FileMode, write func(io.Writer) error) error {
+	dir, base := filepath.Split(target)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return errCannotReplace
+		}
+		return fmt.Errorf("cannot create temporary file in '%s': %w", dir, err)
+	}
+
+	// Remove the temporary file unless it was renamed into place
+	renamed := false
+	defer func() {
+		if !renamed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	// Carry over the original's permissions and owner
+	if info != nil {
+		mode = info.Mode().Perm() | info.Mode()&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky)
+		if err := preserveOwner(tmp, info); err != nil {
+			return errCannotReplace
+		}
+		copyXattrs(target, tmp)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return fmt.Errorf("cannot set permissions on '%s': %w", tmp.Name(), err)
+	}
+
+	if err := write(tmp); err != nil {
+		return fmt.Errorf("cannot write '%s': %w", tmp.Name(), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("cannot flush '%s' to disk: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot close '%s': %w", tmp.Name(), err)
+	}
+
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("cannot replace '%s': %w", target, err)
+	}
+	renamed = true
+
+	// Make the rename itself durable; not every platform can sync a
+	// directory, so failures are ignored
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// writeInPlace overwrites target directly, keeping its inode and with it
+// the owner and permissions
+func writeInPlace(target string, write func(io.Writer) error) error {
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open '%s' for writing: %w", target, err)
+	}
+
+	if err := write(file); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot write '%s': %w", target, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot flush '%s' to disk: %w", target, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("cannot close '%s': %w", target, err)
+	}
+	return nil
+}
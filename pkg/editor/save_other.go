@@ -0,0 +1,24 @@
+//go:build !linux && !darwin
+
+package editor
+
+import "os"
+
+// newFileMode returns the permissions of a newly created file
+func newFileMode() os.FileMode {
+	return 0644
+}
+
+// preserveOwner is a no-op where file ownership isn't carried over
+func preserveOwner(file *os.File, info os.FileInfo) error {
+	return nil
+}
+
+// copyXattrs is a no-op where extended attributes aren't supported
+func copyXattrs(src string, dst *os.File) {}
+
+// processAlive reports false where processes can't be probed, so a swap
+// file is never taken to belong to a running editor
+func processAlive(pid int) bool {
+	return false
+}
@@ -0,0 +1,81 @@
+//go:build linux || darwin
+
+package editor
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// umask is the process umask, read once at startup: reading it means
+// setting it, which would briefly change the permissions of files created
+// by other goroutines
+var umask = func() os.FileMode {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return os.FileMode(mask)
+}()
+
+// newFileMode returns the permissions of a newly created file under the
+// umask
+func newFileMode() os.FileMode {
+	return 0644 &^ umask
+}
+
+// preserveOwner gives file the owner and group described by info. It fails
+// when the user isn't allowed to hand the file to its original owner.
+func preserveOwner(file *os.File, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if int(stat.Uid) == os.Geteuid() && int(stat.Gid) == os.Getegid() {
+		return nil
+	}
+	return file.Chown(int(stat.Uid), int(stat.Gid))
+}
+
+// copyXattrs copies the extended attributes of the file at src to dst.
+// Attributes that can't be read or set are skipped.
+func copyXattrs(src string, dst *os.File) {
+	size, err := unix.Listxattr(src, nil)
+	if err != nil || size <= 0 {
+		return
+	}
+	names := make([]byte, size)
+	size, err = unix.Listxattr(src, names)
+	if err != nil {
+		return
+	}
+
+	// Names are separated by NUL bytes
+	start := 0
+	for i := 0; i < size; i++ {
+		if names[i] != 0 {
+			continue
+		}
+		name := string(names[start:i])
+		start = i + 1
+
+		valueSize, err := unix.Getxattr(src, name, nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, valueSize)
+		if valueSize, err = unix.Getxattr(src, name, value); err != nil {
+			continue
+		}
+		unix.Fsetxattr(int(dst.Fd()), name, value[:valueSize], 0)
+	}
+}
+
+// processAlive reports whether a process with the given pid is running
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,92 @@
+package editor
+
+import "github.com/gdamore/tcell/v2"
+
+// updateSelectionForMove starts or keeps the selection when a movement key is
+// pressed with Shift held, and drops it for a plain movement
+func (e *Editor) updateSelectionForMove(ev *tcell.EventKey) {
+	if ev.Modifiers()&tcell.ModShift == 0 {
+		e.clearSelection()
+		return
+	}
+	if !e.selecting {
+		e.selecting = true
+		e.anchor = e.cursorPos()
+	}
+}
+
+// hasSelection reports whether a non-empty range is selected
+func (e *Editor) hasSelection() bool {
+	if !e.selecting {
+		return false
+	}
+	start, end := e.selectionRange()
+	return start != end
+}
+
+// clearSelection drops the current selection
+func (e *Editor) clearSelection() {
+	e.selecting = false
+}
+
+// selectionRange returns the selected range ordered from start to end
+func (e *Editor) selectionRange() (position, position) {
+	start := e.clampPosition(e.anchor)
+	end := e.clampPosition(e.cursorPos())
+	if end.line < start.line || (end.line == start.line && end.col < start.col) {
+		start, end = end, start
+	}
+	return start, end
+}
+
+// inSelection reports whether the character at line, col is selected. A
+// column past the end of a line stands for its line break.
+func (e *Editor) inSelection(line, col int) bool {
+	if !e.hasSelection() {
+		return false
+	}
+	start, end := e.selectionRange()
+	if line < start.line || line > end.line {
+		return false
+	}
+	if line == start.line && col < start.col {
+		return false
+	}
+	if line == end.line && col >= end.col {
+		return false
+	}
+	return true
+}
+
+// deleteSelection removes the selected text as a single undo step and
+// leaves the cursor where it started. It reports whether anything was removed.
+func (e *Editor) deleteSelection() bool {
+	if !e.hasSelection() {
+		e.clearSelection()
+		return false
+	}
+
+	start, end := e.selectionRange()
+	e.clearSelection()
+	e.history.seal()
+	e.deleteText(start, end, groupNone)
+	e.setCursor(start)
+	return true
+}
+
+// insertAtCursor inserts text at the cursor, replacing the selection if
+// there is one, and moves the cursor past the inserted text
+func (e *Editor) insertAtCursor(text string, group undoGroup) {
+	if !e.hasSelection() {
+		e.clearSelection()
+		e.setCursor(e.insertText(e.cursorPos(), text, group))
+		return
+	}
+
+	// Replacing a selection is undone in one step
+	e.history.begin(e.cursorPos())
+	e.deleteSelection()
+	e.setCursor(e.insertText(e.cursorPos(), text, groupNone))
+	e.history.end(e.cursorPos())
+	e.history.seal()
+}
@@ -0,0 +1,122 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// signalEvent is posted to the event loop when the process receives a
+// signal asking it to stop
+type signalEvent struct {
+	tcell.EventTime
+	signal os.Signal
+}
+
+// watchSignals passes interrupt, hangup and terminate signals to the event
+// loop until the editor quits, so that they end the editor the same way as
+// the exit key rather than killing it outright
+func (e *Editor) watchSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGHUP, syscall.SIGTERM)
+
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-e.quit:
+				return
+			case sig := <-signals:
+				ev := &signalEvent{signal: sig}
+				ev.SetEventNow()
+				e.screen.PostEvent(ev)
+			}
+		}
+	}()
+}
+
+// pollEvent waits for the next event. A hangup or terminate signal ends the
+// editor straight away, whatever it is doing, since the terminal may be gone
+// and nothing can be asked; an interrupt is returned like any other event.
+func (e *Editor) pollEvent() tcell.Event {
+	ev := e.screen.PollEvent()
+	if sig, ok := ev.(*signalEvent); ok && sig.signal != os.Interrupt {
+		e.shutdown(false)
+		os.Exit(1)
+	}
+	return ev
+}
+
+// shutdown is the one way the editor ends. When the user can be asked, it
+// offers to save each modified buffer, and returns true if the user
+// cancelled and the editor keeps running. Otherwise each modified buffer is
+// written to an emergency file and the files written are listed once the
+// terminal is restored. Swap files are only removed when the user has been
+// asked about every buffer.
+func (e *Editor) shutdown(interactive bool) bool {
+	var saved []string
+	if interactive {
+		for idx, doc := range e.documents {
+			if !doc.modified {
+				continue
+			}
+
+			// Show the buffer being asked about behind the dialog
+			e.switchDocument(idx)
+			e.draw()
+			if !e.promptSaveBeforeExit() {
+				return true
+			}
+		}
+		e.removeSwapFiles()
+	} else {
+		for _, doc := range e.documents {
+			if !doc.modified {
+				continue
+			}
+			if path, err := writeEmergencyFile(doc); err == nil {
+				saved = append(saved, path)
+			}
+
+			// Bring the swap file up to date as well, so the changes are
+			// offered for recovery when the file is next opened
+			if e.settings.SwapFiles && doc.mayWriteSwap() {
+				writeSwap(doc)
+			}
+		}
+	}
+
+	close(e.quit)
+	e.screen.Fini()
+	for _, path := range saved {
+		fmt.Fprintln(os.Stderr, "Buffer written to", path)
+	}
+	return false
+}
+
+// writeEmergencyFile saves the text of doc in its file format to a new file
+// named after it with a ".save" suffix, numbered if that file already
+// exists. It returns the path written.
+func writeEmergencyFile(doc *document) (string, error) {
+	path := doc.filePath + ".save"
+	for n := 1; ; n++ {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			path = fmt.Sprintf("%s.save.%d", doc.filePath, n)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if _, err := doc.buf.WriteTo(&formatWriter{w: file, format: doc.format}); err != nil {
+			file.Close()
+			return "", err
+		}
+		return path, file.Close()
+	}
+}
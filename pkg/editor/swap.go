@@ -0,0 +1,261 @@
+package editor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"pow/pkg/buffer"
+	"pow/pkg/diff"
+	"pow/pkg/syntax"
+)
+
+// Modified buffers are copied to a swap file next to their file every few
+// seconds, so that edits survive a crash or a dropped terminal. The swap
+// starts with a short header naming the process and file it belongs to,
+// followed by the text with "\n" line breaks.
+
+// swapMagic is the first line of every swap file
+const swapMagic = "pow swap file"
+
+// swapInfo is the content of a swap file
+type swapInfo struct {
+	pid  int
+	path string
+	text string
+}
+
+// swapPath returns the swap file used for filePath, a hidden file in the
+// same directory
+func swapPath(filePath string) string {
+	dir, base := filepath.Split(filePath)
+	return filepath.Join(dir, "."+base+".swp")
+}
+
+// writeSwap saves the text of doc to its swap file, readable only by the
+// current user. Like a save, it goes through a temporary file, so a crash
+// while writing leaves the previous swap whole.
+func writeSwap(doc *document) error {
+	path, err := filepath.Abs(doc.filePath)
+	if err != nil {
+		path = doc.filePath
+	}
+
+	return writeAtomic(swapPath(doc.filePath), nil, 0600, func(w io.Writer) error {
+		if _, err := fmt.Fprintf(w, "%s\npid %d\npath %s\n\n", swapMagic, os.Getpid(), path); err != nil {
+			return err
+		}
+		_, err := doc.buf.WriteTo(w)
+		return err
+	})
+}
+
+// readSwap reads and parses the swap file at path
+func readSwap(path string) (swapInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return swapInfo{}, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	info, err := readSwapHeader(r, path)
+	if err != nil {
+		return swapInfo{}, err
+	}
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return swapInfo{}, err
+	}
+	info.text = string(text)
+	return info, nil
+}
+
+// readSwapHeader reads the header of a swap file up to the blank line that
+// ends it, leaving r at the start of the text
+func readSwapHeader(r *bufio.Reader, path string) (swapInfo, error) {
+	var info swapInfo
+	for lineNum := 0; ; lineNum++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return swapInfo{}, fmt.Errorf("'%s' is not a swap file", path)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return info, nil
+		}
+		if lineNum == 0 {
+			if line != swapMagic {
+				return swapInfo{}, fmt.Errorf("'%s' is not a swap file", path)
+			}
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "pid":
+			info.pid, _ = strconv.Atoi(value)
+		case "path":
+			info.path = value
+		}
+	}
+}
+
+// swapInUse reports whether the swap file at path belongs to another pow
+// that is still running, and so must not be written over or removed. Only
+// the header is read.
+func swapInUse(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	info, err := readSwapHeader(bufio.NewReader(file), path)
+	return err == nil && info.pid != os.Getpid() && processAlive(info.pid)
+}
+
+// mayWriteSwap reports whether the document's swap file is this session's
+// to write: one it wrote itself, or one no other session needs
+func (d *document) mayWriteSwap() bool {
+	if d.swapKept {
+		return false
+	}
+	return d.hasSwap || !swapInUse(swapPath(d.filePath))
+}
+
+// updateSwapFiles writes the swap file of every buffer changed since its
+// last swap, and removes the swap of buffers that are no longer modified.
+// A swap that cannot be written is retried after the next edit.
+func (e *Editor) updateSwapFiles() {
+	for _, doc := range e.documents {
+		if !doc.modified {
+			doc.removeSwap()
+			continue
+		}
+		if doc.changes == doc.swapChanges || !doc.mayWriteSwap() {
+			continue
+		}
+
+		doc.swapChanges = doc.changes
+		if err := writeSwap(doc); err == nil {
+			doc.hasSwap = true
+		}
+	}
+}
+
+// removeSwap deletes the document's swap file if it wrote one
+func (d *document) removeSwap() {
+	if !d.hasSwap {
+		return
+	}
+	os.Remove(swapPath(d.filePath))
+	d.hasSwap = false
+}
+
+// removeSwapFiles deletes the swap files of all open buffers
+func (e *Editor) removeSwapFiles() {
+	for _, doc := range e.documents {
+		doc.removeSwap()
+	}
+}
+
+// recoverSwapFiles looks for swap files left behind by an earlier session
+// for each open buffer and asks what to do with them. The swap can be
+// recovered into the buffer, compared with the file first, or discarded;
+// Esc leaves it for later. A swap still in use by another running pow is
+// left to it, and the buffer opened read-only.
+func (e *Editor) recoverSwapFiles() {
+	for idx, doc := range e.documents {
+		path := swapPath(doc.filePath)
+		info, err := readSwap(path)
+		if err != nil {
+			continue
+		}
+
+		// Two sessions editing the same file would write over each other's
+		// changes and swap file
+		if info.pid != os.Getpid() && processAlive(info.pid) {
+			doc.readOnly = true
+			doc.swapKept = true
+			e.switchDocument(idx)
+			e.draw()
+			e.showMessage(fmt.Sprintf("%s is being edited by pow process %d; opened read-only", doc.name(), info.pid))
+			continue
+		}
+
+		// Nothing to recover if the swap matches the buffer
+		if info.text == doc.buf.String() {
+			os.Remove(path)
+			continue
+		}
+
+		e.switchDocument(idx)
+		e.draw()
+
+		message := fmt.Sprintf("Found unsaved changes to %s from pow process %d", doc.name(), info.pid)
+
+		options := []string{"Recover", "Diff", "Discard"}
+		for {
+			choice := e.askChoice("Swap File Found", message, options)
+			switch {
+			case choice < 0:
+				// Keep the swap as it is for the next time the file is
+				// opened, without writing this session's changes over it
+				doc.swapKept = true
+			case options[choice] == "Diff":
+				e.showSwapDiff(doc, path, info)
+				e.draw()
+				options = []string{"Recover", "Discard"}
+				continue
+			case options[choice] == "Recover":
+				e.recoverSwap(info)
+			}
+			if !doc.swapKept {
+				os.Remove(path)
+			}
+			break
+		}
+	}
+}
+
+// recoverSwap replaces the content of the current buffer with the text from
+// a swap file. The change is a single undo step, leaving the buffer modified
+// until saved.
+func (e *Editor) recoverSwap(info swapInfo) {
+	lastLine := e.lineCount() - 1
+	e.history.begin(e.cursorPos())
+	e.deleteText(position{0, 0}, position{lastLine, e.lineLen(lastLine)}, groupNone)
+	e.insertText(position{0, 0}, info.text, groupNone)
+	e.setCursor(position{0, 0})
+	e.history.end(e.cursorPos())
+	e.history.seal()
+}
+
+// showSwapDiff opens the differences between the file of doc and its swap
+// as a new buffer in a pane beside it
+func (e *Editor) showSwapDiff(doc *document, path string, info swapInfo) {
+	text := diff.Unified(doc.filePath, path,
+		strings.Split(doc.buf.String(), "\n"), strings.Split(info.text, "\n"), 3)
+
+	diffPath := path + ".diff"
+	diffDoc := &document{
+		filePath:    diffPath,
+		buf:         buffer.New(text),
+		highlighter: syntax.NewHighlighter(diffPath),
+		history:     newHistory(),
+		savedState:  -1,
+	}
+	diffDoc.applyIndentStyle(e.settings)
+
+	e.documents = append(e.documents, diffDoc)
+	e.splitPane(true)
+	e.switchDocument(len(e.documents) - 1)
+
+	// Keep the buffer being asked about in focus
+	e.cyclePane(-1)
+}
@@ -0,0 +1,119 @@
+package editor
+
+import (
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
+)
+
+// Columns in the editor are byte offsets into a line, always kept on a
+// grapheme cluster boundary. These helpers move between clusters and map
+// byte offsets to and from screen cells.
+
+// nextGrapheme returns the offset just past the grapheme cluster at col
+func nextGrapheme(line string, col int) int {
+	if col >= len(line) {
+		return len(line)
+	}
+	cluster, _, _, _ := uniseg.FirstGraphemeClusterInString(line[col:], -1)
+	return col + len(cluster)
+}
+
+// prevGrapheme returns the offset of the grapheme cluster before col
+func prevGrapheme(line string, col int) int {
+	prev := 0
+	for pos := 0; pos < col && pos < len(line); pos = nextGrapheme(line, pos) {
+		prev = pos
+	}
+	return prev
+}
+
+// graphemeWidth returns the number of screen cells a grapheme cluster takes
+// when drawn at screen column x. A tab runs to the next tab stop; anything
+// else is sized by its first rune, the same way tcell sizes a cell.
+func graphemeWidth(cluster string, x, tabWidth int) int {
+	if cluster == "\t" {
+		return tabWidth - x%tabWidth
+	}
+	r, _ := utf8.DecodeRuneInString(cluster)
+	return max(1, runewidth.RuneWidth(r))
+}
+
+// splitGrapheme separates a cluster into the main rune and combining runes
+// expected by tcell's SetContent. A control character, such as a carriage
+// return kept in the text, is shown as its symbol rather than sent to the
+// terminal.
+func splitGrapheme(cluster string) (rune, []rune) {
+	runes := []rune(cluster)
+	if len(runes) == 0 {
+		return ' ', nil
+	}
+	switch r := runes[0]; {
+	case r < 0x20:
+		return 0x2400 + r, runes[1:] // ␀ to ␟, such as ␍
+	case r == 0x7f:
+		return '␡', runes[1:]
+	}
+	return runes[0], runes[1:]
+}
+
+// screenColumn returns the screen column at which offset col of line starts
+func screenColumn(line string, col, tabWidth int) int {
+	x := 0
+	for pos := 0; pos < col && pos < len(line); {
+		next := nextGrapheme(line, pos)
+		x += graphemeWidth(line[pos:next], x, tabWidth)
+		pos = next
+	}
+	return x
+}
+
+// byteColumn returns the offset of the grapheme cluster covering screen
+// column x, or the end of the line if it is shorter
+func byteColumn(line string, x, tabWidth int) int {
+	cells := 0
+	for pos := 0; pos < len(line); {
+		next := nextGrapheme(line, pos)
+		cells += graphemeWidth(line[pos:next], cells, tabWidth)
+		if cells > x {
+			return pos
+		}
+		pos = next
+	}
+	return len(line)
+}
+
+// stringWidth returns the number of screen cells text takes
+func stringWidth(text string) int {
+	return runewidth.StringWidth(text)
+}
+
+// drawText draws text starting at x, y and returns the column after it
+func (e *Editor) drawText(x, y int, text string, style tcell.Style) int {
+	for _, r := range text {
+		e.screen.SetContent(x, y, r, nil, style)
+		x += max(1, runewidth.RuneWidth(r))
+	}
+	return x
+}
+
+// drawTextClipped draws as much of text starting at x, y as fits before
+// column right, and returns the column after it
+func (e *Editor) drawTextClipped(x, y, right int, text string, style tcell.Style) int {
+	for _, r := range text {
+		if x+max(1, runewidth.RuneWidth(r)) > right {
+			break
+		}
+		x = e.drawText(x, y, string(r), style)
+	}
+	return x
+}
+
+// drawCentred draws text centred between columns left and right, clipped
+// to fit
+func (e *Editor) drawCentred(left, right, y int, text string, style tcell.Style) {
+	x := max(left+(right-left-stringWidth(text))/2, left)
+	e.drawTextClipped(x, y, right, text, style)
+}
@@ -0,0 +1,100 @@
+package editor
+
+// viewState is the cursor, scroll position and selection of a view
+type viewState struct {
+	cursorX int
+	cursorY int
+	scrollY int // Track vertical scroll position
+	scrollX int // First screen column shown, for lines wider than the pane
+
+	// Row of the top line shown first when lines are soft wrapped
+	scrollRow int
+
+	// Selection state, the selected range runs from anchor to the cursor
+	selecting bool
+	anchor    position
+}
+
+// view shows a document in one pane of the screen. Several views may show
+// the same document, each with its own cursor and scroll position.
+type view struct {
+	*document
+	viewState
+
+	// Screen area of the pane, updated each time the screen is drawn
+	x      int
+	y      int
+	width  int
+	height int
+
+	// Line after the last one drawn in the pane
+	bottomLine int
+}
+
+// viewsOf returns the views other than the current one that show doc
+func (e *Editor) viewsOf(doc *document) []*view {
+	var views []*view
+	for _, v := range e.root.views() {
+		if v != e.view && v.document == doc {
+			views = append(views, v)
+		}
+	}
+	return views
+}
+
+// shiftViewsForInsert keeps the other views of the current document on the
+// same text after text is inserted between pos and end
+func (e *Editor) shiftViewsForInsert(pos, end position) {
+	for _, v := range e.viewsOf(e.document) {
+		v.anchor = shiftForInsert(v.anchor, pos, end)
+		v.setPos(shiftForInsert(position{v.cursorY, v.cursorX}, pos, end))
+		if pos.line < v.scrollY {
+			v.scrollY += end.line - pos.line
+		}
+	}
+}
+
+// shiftViewsForDelete keeps the other views of the current document on the
+// same text after the text between start and end is removed
+func (e *Editor) shiftViewsForDelete(start, end position) {
+	for _, v := range e.viewsOf(e.document) {
+		v.anchor = shiftForDelete(v.anchor, start, end)
+		v.setPos(shiftForDelete(position{v.cursorY, v.cursorX}, start, end))
+		if start.line < v.scrollY {
+			v.scrollY = max(v.scrollY-(end.line-start.line), start.line)
+		}
+	}
+}
+
+// setPos moves the view's cursor to pos
+func (v *view) setPos(pos position) {
+	v.cursorY = pos.line
+	v.cursorX = pos.col
+}
+
+// shiftForInsert returns where pos ends up after text is inserted between
+// at and end
+func shiftForInsert(pos, at, end position) position {
+	if pos.line < at.line || (pos.line == at.line && pos.col < at.col) {
+		return pos
+	}
+	if pos.line == at.line {
+		return position{end.line, end.col + pos.col - at.col}
+	}
+	return position{pos.line + end.line - at.line, pos.col}
+}
+
+// shiftForDelete returns where pos ends up after the text between start and
+// end is removed. Positions inside the removed text move to its start.
+func shiftForDelete(pos, start, end position) position {
+	if pos.line < start.line || (pos.line == start.line && pos.col < start.col) {
+		return pos
+	}
+	if pos.line < end.line || (pos.line == end.line && pos.col < end.col) {
+		return start
+	}
+	if pos.line == end.line {
+		return position{start.line, start.col + pos.col - end.col}
+	}
+	return position{pos.line - (end.line - start.line), pos.col}
+}
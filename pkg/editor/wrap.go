@@ -0,0 +1,165 @@
+package editor
+
+// With soft wrap on, a line wider than its pane is shown over several screen
+// rows. A line is described by the offsets at which its rows start.
+
+// wrapLine returns the offsets at which the rows of line start when it is
+// wrapped to width columns. Rows break after the last space or tab that
+// fits, or mid-word when a word is wider than the row.
+func wrapLine(line string, width, tabWidth int) []int {
+	starts := []int{0}
+
+	rowStart, rowX := 0, 0   // offset and screen column of the current row
+	breakAt, breakX := -1, 0 // just past the last space in the row
+	for col, x := 0, 0; col < len(line); {
+		next := nextGrapheme(line, col)
+		cluster := line[col:next]
+		cellWidth := graphemeWidth(cluster, x, tabWidth)
+
+		// Start a new row when the cluster doesn't fit, keeping at least
+		// one cluster on every row
+		if x+cellWidth-rowX > width && col > rowStart {
+			if breakAt > rowStart {
+				rowStart, rowX = breakAt, breakX
+			} else {
+				rowStart, rowX = col, x
+			}
+			starts = append(starts, rowStart)
+			breakAt = -1
+			continue
+		}
+
+		if cluster == " " || cluster == "\t" {
+			breakAt, breakX = next, x+cellWidth
+		}
+		x += cellWidth
+		col = next
+	}
+
+	return starts
+}
+
+// wrapRow returns the index of the row in starts that offset col falls on
+func wrapRow(starts []int, col int) int {
+	row := 0
+	for row+1 < len(starts) && starts[row+1] <= col {
+		row++
+	}
+	return row
+}
+
+// lineRows returns the row starts of line i in the current view; without
+// soft wrap every line is a single row
+func (e *Editor) lineRows(i int) []int {
+	if !e.softWrap || i >= e.lineCount() {
+		return []int{0}
+	}
+	return wrapLine(e.line(i), e.wrapWidth(), e.tabWidth)
+}
+
+// wrapWidth returns the number of columns lines are wrapped to, leaving the
+// last column of the pane for the cursor at the end of a line
+func (e *Editor) wrapWidth() int {
+	return max(e.width-e.gutterWidth()-1, 1)
+}
+
+// toggleSoftWrap switches soft wrap on or off
+func (e *Editor) toggleSoftWrap() {
+	e.softWrap = !e.softWrap
+	e.scrollX = 0
+	e.scrollRow = 0
+}
+
+// moveVisualRows moves the cursor up (n < 0) or down by n screen rows,
+// keeping it in the same screen column within the row where possible
+func (e *Editor) moveVisualRows(n int) {
+	starts := e.lineRows(e.cursorY)
+	row := wrapRow(starts, e.cursorX)
+
+	line := ""
+	if e.cursorY < e.lineCount() {
+		line = e.line(e.cursorY)
+	}
+	x := screenColumn(line, e.cursorX, e.tabWidth) - screenColumn(line, starts[row], e.tabWidth)
+
+	for ; n < 0; n++ {
+		if row > 0 {
+			row--
+		} else if e.cursorY > 0 {
+			e.cursorY--
+			starts = e.lineRows(e.cursorY)
+			row = len(starts) - 1
+		} else {
+			break
+		}
+	}
+	for ; n > 0; n-- {
+		if row < len(starts)-1 {
+			row++
+		} else if e.cursorY < e.lineCount() {
+			e.cursorY++
+			starts = e.lineRows(e.cursorY)
+			row = 0
+		} else {
+			break
+		}
+	}
+
+	if e.cursorY >= e.lineCount() {
+		// We're on the extra line beyond content
+		e.cursorX = 0
+		return
+	}
+	line = e.line(e.cursorY)
+
+	// Find the cluster covering column x of the row. A row that wraps ends
+	// before the next row starts, so the cursor stays on its last cluster.
+	end := len(line)
+	if row+1 < len(starts) {
+		end = prevGrapheme(line, starts[row+1])
+	}
+	rowX := screenColumn(line, starts[row], e.tabWidth)
+	cells := rowX
+	col := starts[row]
+	for col < end {
+		next := nextGrapheme(line, col)
+		cells += graphemeWidth(line[col:next], cells, e.tabWidth)
+		if cells > rowX+x {
+			break
+		}
+		col = next
+	}
+	e.cursorX = col
+}
+
+// ensureVisibleWrapped scrolls so that the cursor row is on screen when
+// lines are wrapped. The top of the pane is a line and a row within it.
+func (e *Editor) ensureVisibleWrapped(contentHeight int) {
+	e.scrollX = 0
+	e.scrollRow = min(e.scrollRow, len(e.lineRows(e.scrollY))-1)
+	row := wrapRow(e.lineRows(e.cursorY), e.cursorX)
+
+	// If cursor is above the visible area, scroll up to its row
+	if e.cursorY < e.scrollY || (e.cursorY == e.scrollY && row < e.scrollRow) {
+		e.scrollY, e.scrollRow = e.cursorY, row
+		return
+	}
+
+	// Walk up from the cursor row; if the top of the pane is within reach
+	// the cursor is visible, otherwise the row reached becomes the top
+	topLine, topRow := e.cursorY, row
+	for rows := 1; rows < contentHeight; rows++ {
+		if topLine == e.scrollY && topRow == e.scrollRow {
+			return
+		}
+		if topRow > 0 {
+			topRow--
+		} else if topLine > 0 {
+			topLine--
+			topRow = len(e.lineRows(topLine)) - 1
+		} else {
+			break
+		}
+	}
+	e.scrollY, e.scrollRow = topLine, topRow
+}
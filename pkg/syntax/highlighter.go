@@ -25,11 +25,28 @@ type ColorSegment struct {
 	Style    tcell.Style
 }
 
+// LineSource provides the text to highlight one line at a time
+type LineSource interface {
+	LineCount() int
+	Line(i int) string
+}
+
+// syncLines is how far above the requested window lexing restarts when
+// nothing is cached near it, trading accuracy for speed on large jumps
+const syncLines = 500
+
 // Highlighter manages syntax highlighting
 type Highlighter struct {
 	lexer     chroma.Lexer
 	formatter chroma.Formatter
 	style     *chroma.Style
+
+	// Cache of highlighted lines covering [base, base+len(lines)). syncs[i]
+	// reports whether lexing can restart at the start of line base+i, so it
+	// holds one more entry than lines.
+	base  int
+	lines []ColoredLine
+	syncs []bool
 }
 
 // NewHighlighter creates a new syntax highlighter for the specified file
@@ -49,6 +66,22 @@ func NewHighlighter(filePath string) *Highlighter {
 		lexer = lexers.Fallback
 	}
 
+	return newHighlighter(lexer)
+}
+
+// NewHighlighterForLanguage creates a highlighter for the named language,
+// such as "go" or "python", whatever the file is called. It returns nil if
+// no lexer goes by that name.
+func NewHighlighterForLanguage(name string) *Highlighter {
+	lexer := lexers.Get(name)
+	if lexer == nil {
+		return nil
+	}
+	return newHighlighter(lexer)
+}
+
+// newHighlighter creates a highlighter using lexer
+func newHighlighter(lexer chroma.Lexer) *Highlighter {
 	// Use a coalescing lexer to improve performance
 	lexer = chroma.Coalesce(lexer)
 
@@ -156,6 +189,139 @@ func (h *Highlighter) HighlightContent(content string) []ColoredLine {
 	return result
 }
 
+// Invalidate drops cached highlighting from line onwards; it must be called
+// whenever that line or any line after it changes
+func (h *Highlighter) Invalidate(line int) {
+	idx := line - h.base
+	if idx < 0 {
+		h.lines = nil
+		h.syncs = nil
+		return
+	}
+	if idx < len(h.lines) {
+		h.lines = h.lines[:idx]
+		h.syncs = h.syncs[:idx+1]
+	}
+}
+
+// HighlightLines returns the highlighted lines from start up to end. Lexer
+// state is only known at cached line boundaries, so lexing resumes from the
+// last boundary that is safe to restart at rather than the top of the file.
+func (h *Highlighter) HighlightLines(src LineSource, start, end int) []ColoredLine {
+	end = min(end, src.LineCount())
+	if start >= end {
+		return nil
+	}
+
+	cachedEnd := h.base + len(h.lines)
+	if len(h.syncs) == 0 || start < h.base || start > cachedEnd+syncLines {
+		// Too far from anything cached, start afresh a little above the window
+		h.base = max(0, start-syncLines)
+		h.lines = nil
+		h.syncs = []bool{true}
+		cachedEnd = h.base
+	}
+
+	if end > cachedEnd {
+		// Drop cached lines back to the last safe restart point
+		resume := len(h.lines)
+		for !h.syncs[resume] {
+			resume--
+		}
+		h.lines = h.lines[:resume]
+		h.syncs = h.syncs[:resume+1]
+
+		h.lexLines(src, h.base+resume, end)
+	}
+
+	h.trim(start)
+	return h.lines[start-h.base : end-h.base]
+}
+
+// trim drops cached lines more than syncLines above start once there are
+// twice that many, so paging through a large file keeps a bounded cache.
+// The cache then begins at the nearest point above that lexing can restart
+// at, or where it is cut if there is none, as when starting afresh.
+func (h *Highlighter) trim(start int) {
+	if start-h.base <= 2*syncLines {
+		return
+	}
+
+	cut := start - syncLines - h.base
+	for i := cut; i > 0; i-- {
+		if h.syncs[i] {
+			cut = i
+			break
+		}
+	}
+
+	// Copy what is kept so the dropped lines can be freed
+	h.base += cut
+	h.lines = append([]ColoredLine(nil), h.lines[cut:]...)
+	h.syncs = append([]bool(nil), h.syncs[cut:]...)
+	h.syncs[0] = true
+}
+
+// lexLines tokenises lines [start, end) and appends them to the cache, which
+// must currently end at start
+func (h *Highlighter) lexLines(src LineSource, start, end int) {
+	lines := make([]string, 0, end-start)
+	var text strings.Builder
+	for i := start; i < end; i++ {
+		line := src.Line(i)
+		lines = append(lines, line)
+		text.WriteString(line)
+		text.WriteByte('\n')
+	}
+
+	result := make([]ColoredLine, len(lines))
+	syncs := make([]bool, len(lines))
+	for i, line := range lines {
+		result[i] = ColoredLine{Text: line, Colors: []ColorSegment{}}
+	}
+
+	iterator, err := h.lexer.Tokenise(nil, text.String())
+	if err == nil {
+		lineIdx := 0
+		startPos := 0
+
+		for token := iterator(); token != chroma.EOF && lineIdx < len(lines); token = iterator() {
+			tokenStyle := h.style.Get(token.Type)
+			tcellStyle := chromaStyleToTcellStyle(tokenStyle)
+
+			// A line break inside plain text means the lexer is not in the
+			// middle of a string or comment, so the next line can be lexed
+			// on its own
+			restartable := token.Type.InCategory(chroma.Text) || token.Type == chroma.Whitespace
+
+			tokenLines := strings.Split(token.Value, "\n")
+			for i, tokenLine := range tokenLines {
+				if i > 0 {
+					syncs[lineIdx] = restartable
+					lineIdx++
+					startPos = 0
+				}
+				if lineIdx >= len(lines) {
+					break
+				}
+
+				// Skip segments with no foreground color
+				if len(tokenLine) > 0 && tokenStyle.Colour != 0 {
+					result[lineIdx].Colors = append(result[lineIdx].Colors, ColorSegment{
+						StartCol: startPos,
+						EndCol:   startPos + len(tokenLine),
+						Style:    tcellStyle,
+					})
+				}
+				startPos += len(tokenLine)
+			}
+		}
+	}
+
+	h.lines = append(h.lines, result...)
+	h.syncs = append(h.syncs, syncs...)
+}
+
 // HighlightLine highlights a single line of text
 func (h *Highlighter) HighlightLine(line string) ColoredLine {
 	result := ColoredLine{